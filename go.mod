@@ -5,10 +5,12 @@ go 1.22
 require (
 	github.com/spf13/cobra v1.8.1
 	github.com/ulikunitz/xz v0.5.12
+	golang.org/x/crypto v0.31.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 )
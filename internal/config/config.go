@@ -14,11 +14,28 @@ type NetworkConfig struct {
 	Retries        int `yaml:"retries"`
 }
 
+type CacheConfig struct {
+	ReleaseTTLSeconds int `yaml:"releaseTTLSeconds"`
+}
+
+type SourceConfig struct {
+	Host   string `yaml:"host"`
+	APIURL string `yaml:"apiURL"`
+}
+
+type CredentialConfig struct {
+	Token string `yaml:"token"`
+}
+
 type Config struct {
-	PackagesDir string        `yaml:"packagesDir"`
-	StateDir    string        `yaml:"stateDir"`
-	CacheDir    string        `yaml:"cacheDir"`
-	Network     NetworkConfig `yaml:"network"`
+	PackagesDir string                      `yaml:"packagesDir"`
+	StateDir    string                      `yaml:"stateDir"`
+	CacheDir    string                      `yaml:"cacheDir"`
+	KeysDir     string                      `yaml:"keysDir"`
+	Network     NetworkConfig               `yaml:"network"`
+	Cache       CacheConfig                 `yaml:"cache"`
+	Sources     map[string]SourceConfig     `yaml:"sources"`
+	Credentials map[string]CredentialConfig `yaml:"credentials"`
 }
 
 func DefaultConfig() Config {
@@ -26,10 +43,14 @@ func DefaultConfig() Config {
 		PackagesDir: "/var/lib/ghpm/packages",
 		StateDir:    "/var/lib/ghpm/state",
 		CacheDir:    "/var/cache/ghpm",
+		KeysDir:     "/etc/ghpm/keys",
 		Network: NetworkConfig{
 			TimeoutSeconds: 30,
 			Retries:        2,
 		},
+		Cache: CacheConfig{
+			ReleaseTTLSeconds: 300,
+		},
 	}
 }
 
@@ -55,6 +76,15 @@ func (c Config) HTTPTimeout() time.Duration {
 	return time.Duration(c.Network.TimeoutSeconds) * time.Second
 }
 
+// ReleaseTTL is how long cached release listings are trusted without
+// revalidation. Zero revalidates on every lookup.
+func (c Config) ReleaseTTL() time.Duration {
+	if c.Cache.ReleaseTTLSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(c.Cache.ReleaseTTLSeconds) * time.Second
+}
+
 func (c Config) EnsureDirs(root string) error {
 	dirs := []string{
 		filepath.Join(root, c.PackagesDir),
@@ -0,0 +1,207 @@
+package credentials
+
+import (
+	"bufio"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ghpm/internal/config"
+)
+
+type Credential struct {
+	Token    string
+	Username string
+	Password string
+}
+
+// Store holds one credential per host, gathered from the config file, the
+// environment and ~/.netrc, in that order of precedence.
+type Store struct {
+	hosts map[string]Credential
+}
+
+var envTokens = []struct {
+	kind string
+	env  string
+	host string
+}{
+	{kind: "github", env: "GITHUB_TOKEN", host: "github.com"},
+	{kind: "gitlab", env: "GITLAB_TOKEN", host: "gitlab.com"},
+}
+
+func Load(cfg config.Config) *Store {
+	s := &Store{hosts: map[string]Credential{}}
+	for host, c := range cfg.Credentials {
+		if c.Token != "" {
+			s.add(host, Credential{Token: c.Token})
+		}
+	}
+	for _, e := range envTokens {
+		token := os.Getenv(e.env)
+		if token == "" {
+			continue
+		}
+		host := e.host
+		if defaults := cfg.Sources[e.kind]; defaults.Host != "" {
+			host = hostname(defaults.Host)
+		} else if defaults.APIURL != "" {
+			host = hostname(defaults.APIURL)
+		}
+		s.add(host, Credential{Token: token})
+	}
+	for host, c := range loadNetrc(netrcPath()) {
+		s.add(host, c)
+	}
+	return s
+}
+
+// hostname returns the host name of a source host or API URL, which may
+// carry a scheme, a port or a path, as requests are matched by host name.
+func hostname(host string) string {
+	raw := host
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return host
+	}
+	return u.Hostname()
+}
+
+func (s *Store) add(host string, c Credential) {
+	host = strings.ToLower(host)
+	if _, ok := s.hosts[host]; ok {
+		return
+	}
+	s.hosts[host] = c
+}
+
+// Lookup returns the credential for host. API hosts such as api.github.com
+// fall back to the credential of their parent domain.
+func (s *Store) Lookup(host string) (Credential, bool) {
+	if s == nil {
+		return Credential{}, false
+	}
+	host = strings.ToLower(host)
+	if c, ok := s.hosts[host]; ok {
+		return c, true
+	}
+	if trimmed, ok := strings.CutPrefix(host, "api."); ok {
+		c, ok := s.hosts[trimmed]
+		return c, ok
+	}
+	return Credential{}, false
+}
+
+// Secrets lists every token and password so they can be redacted from logs.
+func (s *Store) Secrets() []string {
+	if s == nil {
+		return nil
+	}
+	var secrets []string
+	for _, c := range s.hosts {
+		if c.Token != "" {
+			secrets = append(secrets, c.Token)
+		}
+		if c.Password != "" {
+			secrets = append(secrets, c.Password)
+		}
+	}
+	return secrets
+}
+
+// Transport wraps base so every request carries the credential of its
+// target host. Redirects are new requests, so a redirect to another host
+// (e.g. a storage bucket) never sees the original host's credential.
+func (s *Store) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, store: s}
+}
+
+type transport struct {
+	base  http.RoundTripper
+	store *Store
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" || req.URL.Scheme != "https" {
+		return t.base.RoundTrip(req)
+	}
+	c, ok := t.store.Lookup(req.URL.Hostname())
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	return t.base.RoundTrip(req)
+}
+
+func netrcPath() string {
+	if p := os.Getenv("NETRC"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// loadNetrc parses the machine entries of a netrc file. A missing or
+// unreadable file yields no credentials.
+func loadNetrc(path string) map[string]Credential {
+	creds := map[string]Credential{}
+	if path == "" {
+		return creds
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return creds
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Split(bufio.ScanWords)
+	var host string
+	var cur Credential
+	flush := func() {
+		if host != "" && cur.Password != "" {
+			creds[host] = cur
+		}
+		host = ""
+		cur = Credential{}
+	}
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "machine":
+			flush()
+			if scanner.Scan() {
+				host = scanner.Text()
+			}
+		case "default":
+			flush()
+		case "login":
+			if scanner.Scan() {
+				cur.Username = scanner.Text()
+			}
+		case "password":
+			if scanner.Scan() {
+				cur.Password = scanner.Text()
+			}
+		case "macdef":
+			flush()
+			return creds
+		}
+	}
+	flush()
+	return creds
+}
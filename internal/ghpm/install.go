@@ -3,6 +3,7 @@ package ghpm
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -13,9 +14,9 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
-	"runtime"
 	"strings"
 
+	"ghpm/internal/httpclient"
 	"ghpm/internal/manifest"
 	"ghpm/internal/source"
 	"ghpm/internal/state"
@@ -35,20 +36,33 @@ func (m *Manager) Install(name string, opts InstallOptions) (state.Receipt, erro
 	if err != nil {
 		return state.Receipt{}, err
 	}
+	if opts.Channel != "" {
+		mf.Source.Channel = opts.Channel
+	}
+	platform, err := m.platform(mf, opts.Platform)
+	if err != nil {
+		return state.Receipt{}, err
+	}
 	m.Logger.Infof("install %s", mf.Name)
 
 	installed, err := state.LoadInstalled(state.InstalledPath(m.StateDir()))
 	if err != nil {
 		return state.Receipt{}, err
 	}
+	resolved, release, err := m.resolveVersion(mf, opts.Version)
+	if err != nil {
+		return state.Receipt{}, err
+	}
+	// A range or channel given on the command line is kept for later
+	// upgrades.
+	var constraint string
+	if source.IsConstraint(opts.Version) {
+		constraint = opts.Version
+	}
 	if entry, ok := installed.Installed[mf.Name]; ok && !opts.Force {
-		resolved, _, err := m.resolveVersion(mf, opts.Version)
-		if err != nil {
-			return state.Receipt{}, err
-		}
 		if resolved != "" && resolved == entry.Version {
 			receiptPath := state.ReceiptPath(m.StateDir(), mf.Name)
-			if receipt, err := state.LoadReceipt(receiptPath); err == nil {
+			if receipt, err := state.LoadReceipt(receiptPath); err == nil && receipt.Source.Constraint == constraint && receipt.Source.Channel == opts.Channel {
 				m.Logger.Infof("already installed %s %s", mf.Name, resolved)
 				return receipt, nil
 			}
@@ -66,17 +80,16 @@ func (m *Manager) Install(name string, opts InstallOptions) (state.Receipt, erro
 		return state.Receipt{}, err
 	}
 
-	platform := state.Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
-	resolved, release, err := m.resolveVersion(mf, opts.Version)
-	if err != nil {
-		return state.Receipt{}, err
-	}
 	if resolved != "" {
 		m.Logger.Infof("resolved %s", resolved)
 	}
 
+	version := release.Version
+	if version == "" {
+		version = resolved
+	}
 	ctx := manifest.TemplateContext{
-		Version: resolved,
+		Version: version,
 		Tag:     resolved,
 		OS:      platform.OS,
 		Arch:    platform.Arch,
@@ -90,7 +103,7 @@ func (m *Manager) Install(name string, opts InstallOptions) (state.Receipt, erro
 	}
 	defer os.RemoveAll(workDir)
 
-	plan, artifacts, err := m.buildPlan(mf, release, ctx, workDir)
+	plan, artifacts, err := m.buildPlan(mf, release, platform, ctx, workDir)
 	if err != nil {
 		return state.Receipt{}, err
 	}
@@ -108,8 +121,8 @@ func (m *Manager) Install(name string, opts InstallOptions) (state.Receipt, erro
 	receipt := state.Receipt{
 		Schema:    1,
 		Name:      mf.Name,
-		Source:    state.ReceiptSource{Kind: mf.Source.Kind, Repo: mf.Source.Repo, Tag: resolved, ReleaseID: release.ID},
-		Platform:  platform,
+		Source:    state.ReceiptSource{Kind: mf.Source.Kind, Repo: mf.Source.Repo, Host: m.sourceFor(mf).Host, Tag: resolved, Version: version, Constraint: constraint, Channel: opts.Channel, ReleaseID: release.ID},
+		Platform:  state.Platform{OS: platform.OS, Arch: platform.Arch},
 		Artifacts: artifacts,
 	}
 
@@ -133,7 +146,11 @@ func (m *Manager) Install(name string, opts InstallOptions) (state.Receipt, erro
 		_ = removeObsoleteFiles(m.Root, previousReceipt, &receipt)
 	}
 
-	m.runHooks(mf.PostInstall)
+	if len(mf.PostInstall) > 0 && !platform.Native() {
+		m.Logger.Infof("warning: skipping postInstall hooks of %s: target platform %s cannot run on this host", mf.Name, platform)
+	} else {
+		m.runHooks(manifest.ExpandStrings(mf.PostInstall, ctx))
+	}
 	return receipt, nil
 }
 
@@ -214,19 +231,36 @@ func (m *Manager) Upgrade(name string, opts InstallOptions) (bool, state.Receipt
 		receipt, err := m.Install(name, opts)
 		return true, receipt, err
 	}
+	// Upgrades stay within the range and channel the package was installed
+	// with, on the platform it was installed for.
+	opts.Version = ""
+	if previous, err := state.LoadReceipt(state.ReceiptPath(m.StateDir(), name)); err == nil {
+		opts.Version = previous.Source.Constraint
+		if opts.Channel == "" {
+			opts.Channel = previous.Source.Channel
+		}
+		if opts.Platform == "" && previous.Platform.OS != "" {
+			opts.Platform = previous.Platform.OS + "/" + previous.Platform.Arch
+		}
+	}
 	if opts.DryRun {
 		mf, err := m.LoadManifest(name)
 		if err != nil {
 			return false, state.Receipt{}, err
 		}
-		resolved, _, err := m.resolveVersion(mf, "")
+		if opts.Channel != "" {
+			mf.Source.Channel = opts.Channel
+		}
+		resolved, release, err := m.resolveVersion(mf, opts.Version)
 		if err != nil {
 			return false, state.Receipt{}, err
 		}
+		if release.OrderedTag != "" {
+			m.Logger.Infof("%s: upstream latest release is %s, but %s is the highest version", name, resolved, release.OrderedTag)
+		}
 		receipt := state.Receipt{Name: name, Source: state.ReceiptSource{Tag: resolved}}
 		return resolved != entry.Version, receipt, nil
 	}
-	opts.Version = ""
 	receipt, err := m.Install(name, opts)
 	if err != nil {
 		return false, state.Receipt{}, err
@@ -237,25 +271,99 @@ func (m *Manager) Upgrade(name string, opts InstallOptions) (bool, state.Receipt
 	return true, receipt, nil
 }
 
+// resolveVersion resolves version, which defaults to the manifest's version
+// constraint, to a release tag.
 func (m *Manager) resolveVersion(mf manifest.Manifest, version string) (string, source.Release, error) {
+	if version == "" {
+		version = mf.Version
+	}
 	if mf.Source.Kind == "" {
 		return version, source.Release{}, nil
 	}
-	if mf.Source.Kind == "http" && version == "" {
+	if mf.Source.Kind == "http" && mf.Source.Discovery == nil && version == "" {
 		return "", source.Release{}, nil
 	}
-	resolver, err := source.NewResolver(mf.Source.Kind, m.HTTP)
+	release, err := m.resolveRelease(mf, version)
 	if err != nil {
 		return "", source.Release{}, err
 	}
-	release, err := resolver.ResolveRelease(mf.Source.Repo, version)
+	return release.Tag, release, nil
+}
+
+// resolveRelease resolves a release once per source and version for the
+// lifetime of the manager.
+func (m *Manager) resolveRelease(mf manifest.Manifest, version string) (source.Release, error) {
+	src := m.sourceFor(mf)
+	srcKey, err := json.Marshal(src)
+	if err != nil {
+		return source.Release{}, err
+	}
+	key := string(srcKey) + "@" + version
+	if release, ok := m.releases[key]; ok {
+		return release, nil
+	}
+	cache := &source.Cache{Dir: filepath.Join(m.CacheDir(), "releases"), TTL: m.Config.ReleaseTTL()}
+	resolver, err := source.NewResolver(src, m.HTTP, cache)
 	if err != nil {
-		return "", source.Release{}, err
+		return source.Release{}, err
 	}
-	return release.Tag, release, nil
+	release, err := resolver.ResolveRelease(src.Repo, version)
+	if err != nil {
+		return source.Release{}, err
+	}
+	if m.releases == nil {
+		m.releases = map[string]source.Release{}
+	}
+	m.releases[key] = release
+	return release, nil
 }
 
-func (m *Manager) fetchURL(urlStr string) (string, string, int64, string, error) {
+// sourceFor returns the manifest source with the per-kind defaults from the
+// config applied when the manifest does not name its own host or API URL.
+func (m *Manager) sourceFor(mf manifest.Manifest) manifest.Source {
+	src := mf.Source
+	if src.Host == "" && src.APIURL == "" {
+		defaults := m.Config.Sources[src.Kind]
+		src.Host = defaults.Host
+		src.APIURL = defaults.APIURL
+	}
+	return src
+}
+
+// download describes one remote file to fetch into the download cache.
+type download struct {
+	URL string
+	// Accept overrides the Accept request header.
+	Accept string
+	// Name overrides the file name derived from URL, which is used as cache
+	// hint and for archive format detection.
+	Name string
+	// Digests are the expected "algo:hex" digests of the content. A cached
+	// file that does not match is downloaded again; a fresh one is rejected.
+	Digests []string
+}
+
+func (m *Manager) fetchURL(urlStr string, digests []string) (string, string, int64, string, error) {
+	return m.fetch(download{URL: urlStr, Digests: digests})
+}
+
+// fetchAsset downloads a release asset, verified against the digest the
+// forge recorded for it in addition to digests. Assets of private GitHub
+// sources are requested through the API, which redirects to a signed storage
+// URL; the credential transport only authenticates the API host, so the token
+// is not forwarded to the storage host.
+func (m *Manager) fetchAsset(mf manifest.Manifest, asset source.Asset, digests []string) (string, string, int64, string, error) {
+	if supportedDigest(asset.Digest) {
+		digests = append(digests[:len(digests):len(digests)], strings.ToLower(asset.Digest))
+	}
+	if mf.Source.Private && asset.APIURL != "" {
+		return m.fetch(download{URL: asset.APIURL, Accept: "application/octet-stream", Name: asset.Name, Digests: digests})
+	}
+	return m.fetch(download{URL: asset.URL, Name: asset.Name, Digests: digests})
+}
+
+func (m *Manager) fetch(d download) (string, string, int64, string, error) {
+	urlStr := d.URL
 	cacheDir := filepath.Join(m.CacheDir(), "downloads")
 	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
 		return "", "", 0, "", err
@@ -263,26 +371,38 @@ func (m *Manager) fetchURL(urlStr string) (string, string, int64, string, error)
 	key := sha256.Sum256([]byte(urlStr))
 	name := hex.EncodeToString(key[:])
 	hintName := cacheHintName(urlStr)
+	if d.Name != "" {
+		hintName = sanitizeFilename(d.Name)
+	}
 	cacheName := name
 	if hintName != "" {
 		cacheName = name + "-" + hintName
 	}
 	path := filepath.Join(cacheDir, cacheName)
 	if _, err := os.Stat(path); err == nil {
-		sum, size, err := hashFileWithSize(path)
-		return path, sum, size, hintName, err
+		if err := checkDigests(path, urlStr, d.Digests); err == nil {
+			sum, size, err := hashFileWithSize(path)
+			return path, sum, size, hintName, err
+		}
+		m.Logger.Infof("cached %s does not match its digest, downloading again", urlStr)
+		if err := os.Remove(path); err != nil {
+			return "", "", 0, "", err
+		}
 	}
 	req, err := http.NewRequest(http.MethodGet, urlStr, nil)
 	if err != nil {
 		return "", "", 0, "", err
 	}
+	if d.Accept != "" {
+		req.Header.Set("Accept", d.Accept)
+	}
 	resp, err := m.HTTP.Do(req)
 	if err != nil {
 		return "", "", 0, "", err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return "", "", 0, "", fmt.Errorf("download %s: %s", urlStr, resp.Status)
+		return "", "", 0, "", httpclient.StatusError(resp, "download "+urlStr)
 	}
 	tmp := path + ".tmp"
 	f, err := os.Create(tmp)
@@ -299,6 +419,10 @@ func (m *Manager) fetchURL(urlStr string) (string, string, int64, string, error)
 	if err := f.Sync(); err != nil {
 		return "", "", 0, "", err
 	}
+	if err := checkDigests(tmp, urlStr, d.Digests); err != nil {
+		_ = os.Remove(tmp)
+		return "", "", 0, "", err
+	}
 	if err := os.Rename(tmp, path); err != nil {
 		return "", "", 0, "", err
 	}
@@ -7,22 +7,30 @@ import (
 	"syscall"
 
 	"ghpm/internal/config"
+	"ghpm/internal/credentials"
+	"ghpm/internal/httpclient"
 	"ghpm/internal/manifest"
+	"ghpm/internal/source"
 	"ghpm/internal/ui"
 )
 
 type Manager struct {
-	Config   config.Config
-	Root     string
-	HTTP     *http.Client
-	lockFile *os.File
-	Logger   ui.Logger
+	Config      config.Config
+	Root        string
+	HTTP        *http.Client
+	Credentials *credentials.Store
+	lockFile    *os.File
+	releases    map[string]source.Release
+	Logger      ui.Logger
 }
 
 type InstallOptions struct {
 	Version string
-	Force   bool
-	DryRun  bool
+	Channel string
+	// Platform is the os/arch to install for, defaulting to the host's.
+	Platform string
+	Force    bool
+	DryRun   bool
 }
 
 type RemoveOptions struct {
@@ -31,12 +39,21 @@ type RemoveOptions struct {
 
 func NewManager(cfg config.Config, root string) *Manager {
 	timeout := cfg.HTTPTimeout()
-	client := &http.Client{Timeout: timeout}
+	creds := credentials.Load(cfg)
+	for _, secret := range creds.Secrets() {
+		ui.AddSecret(secret)
+	}
+	client := &http.Client{Transport: &httpclient.Transport{
+		Base:    creds.Transport(nil),
+		Retries: cfg.Network.Retries,
+		Timeout: timeout,
+	}}
 	return &Manager{
-		Config: cfg,
-		Root:   root,
-		HTTP:   client,
-		Logger: ui.NewLogger(ui.LevelNormal, os.Stderr),
+		Config:      cfg,
+		Root:        root,
+		HTTP:        client,
+		Credentials: creds,
+		Logger:      ui.NewLogger(ui.LevelNormal, os.Stderr),
 	}
 }
 
@@ -52,6 +69,10 @@ func (m *Manager) CacheDir() string {
 	return filepath.Join(m.Root, m.Config.CacheDir)
 }
 
+func (m *Manager) KeysDir() string {
+	return filepath.Join(m.Root, m.Config.KeysDir)
+}
+
 func (m *Manager) lock() error {
 	lockPath := filepath.Join(m.Root, "var/lock/ghpm.lock")
 	if err := os.MkdirAll(filepath.Dir(lockPath), 0o755); err != nil {
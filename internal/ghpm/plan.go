@@ -16,43 +16,49 @@ type plan struct {
 	receiptFiles *[]state.ReceiptFile
 }
 
-func (m *Manager) buildPlan(mf manifest.Manifest, release source.Release, ctx manifest.TemplateContext, workDir string) (plan, []state.Artifact, error) {
+func (m *Manager) buildPlan(mf manifest.Manifest, release source.Release, platform source.Platform, ctx manifest.TemplateContext, workDir string) (plan, []state.Artifact, error) {
 	receiptFiles := []state.ReceiptFile{}
 	pl := plan{receiptFiles: &receiptFiles}
 	var artifacts []state.Artifact
+	facts := m.facts(platform)
 	for _, act := range mf.Install {
+		if act.When != nil && !act.When.Matches(facts) {
+			m.Logger.Verbosef("skip %s action: when does not match", act.Type)
+			continue
+		}
+		act = act.Expand(ctx)
 		switch act.Type {
 		case "mkdir":
 			action := *act.Mkdir
-			target := filepath.Join(m.Root, manifest.ExpandTemplate(action.Path, ctx))
+			target := filepath.Join(m.Root, action.Path)
 			pl.targets = append(pl.targets, target)
 			pl.steps = append(pl.steps, func() error {
 				m.Logger.Verbosef("mkdir %s", target)
 				return os.MkdirAll(target, 0o755)
 			})
 			*pl.receiptFiles = append(*pl.receiptFiles, state.ReceiptFile{
-				Path: manifest.ExpandTemplate(action.Path, ctx),
+				Path: action.Path,
 				Type: "dir",
 				Mode: parseMode(action.Mode),
 			})
 		case "symlink":
 			action := *act.Symlink
-			target := filepath.Join(m.Root, manifest.ExpandTemplate(action.Target, ctx))
-			to := manifest.ExpandTemplate(action.To, ctx)
+			target := filepath.Join(m.Root, action.Target)
+			to := action.To
 			pl.targets = append(pl.targets, target)
 			pl.steps = append(pl.steps, func() error {
 				m.Logger.Verbosef("symlink %s -> %s", target, to)
 				return createSymlinkAtomic(target, to)
 			})
 			*pl.receiptFiles = append(*pl.receiptFiles, state.ReceiptFile{
-				Path: manifest.ExpandTemplate(action.Target, ctx),
+				Path: action.Target,
 				Type: "symlink",
 				To:   to,
 			})
 		case "file":
 			action := *act.File
 			src := filepath.Join(mf.PackageDir(), action.Path)
-			target := filepath.Join(m.Root, manifest.ExpandTemplate(action.Target, ctx))
+			target := filepath.Join(m.Root, action.Target)
 			pl.targets = append(pl.targets, target)
 			pl.steps = append(pl.steps, func() error {
 				m.Logger.Verbosef("install file %s -> %s", src, target)
@@ -63,7 +69,7 @@ func (m *Manager) buildPlan(mf manifest.Manifest, release source.Release, ctx ma
 				return plan{}, nil, err
 			}
 			*pl.receiptFiles = append(*pl.receiptFiles, state.ReceiptFile{
-				Path:     manifest.ExpandTemplate(action.Target, ctx),
+				Path:     action.Target,
 				Type:     "file",
 				Mode:     parseMode(action.Mode),
 				SHA256:   sum,
@@ -77,20 +83,27 @@ func (m *Manager) buildPlan(mf manifest.Manifest, release source.Release, ctx ma
 			})
 		case "url":
 			action := *act.URL
-			urlStr := manifest.ExpandTemplate(action.URL, ctx)
-			target := filepath.Join(m.Root, manifest.ExpandTemplate(action.Target, ctx))
+			urlStr := action.URL
+			target := filepath.Join(m.Root, action.Target)
+			digests, err := pinnedDigests(action.SHA256, action.SHA512, platform)
+			if err != nil {
+				return plan{}, nil, err
+			}
 			m.Logger.Infof("download %s", urlStr)
-			localPath, sum, size, _, err := m.fetchURL(urlStr)
+			localPath, sum, size, _, err := m.fetchURL(urlStr, digests)
 			if err != nil {
 				return plan{}, nil, err
 			}
+			if err := m.verifySignature(mf, release, ctx, action.Signature, "", urlStr, localPath); err != nil {
+				return plan{}, nil, err
+			}
 			pl.targets = append(pl.targets, target)
 			pl.steps = append(pl.steps, func() error {
 				m.Logger.Verbosef("install url -> %s", target)
 				return installFileAtomic(target, localPath, parseMode(action.Mode))
 			})
 			*pl.receiptFiles = append(*pl.receiptFiles, state.ReceiptFile{
-				Path:     manifest.ExpandTemplate(action.Target, ctx),
+				Path:     action.Target,
 				Type:     "file",
 				Mode:     parseMode(action.Mode),
 				SHA256:   sum,
@@ -104,44 +117,69 @@ func (m *Manager) buildPlan(mf manifest.Manifest, release source.Release, ctx ma
 			})
 		case "asset":
 			action := *act.Asset
-			action.Name = manifest.ExpandTemplate(action.Name, ctx)
-			action.Pattern = manifest.ExpandTemplate(action.Pattern, ctx)
-			asset, err := source.SelectAsset(release, action)
-			if err != nil {
-				return plan{}, nil, err
+			var assets []source.Asset
+			if action.TargetDir != "" {
+				matches, err := source.SelectAssets(release, action)
+				if err != nil {
+					return plan{}, nil, err
+				}
+				assets = matches
+			} else {
+				asset, err := source.SelectAsset(release, action, platform)
+				if err != nil {
+					return plan{}, nil, err
+				}
+				assets = []source.Asset{asset}
 			}
-			target := filepath.Join(m.Root, manifest.ExpandTemplate(action.Target, ctx))
-			m.Logger.Infof("download %s %s", asset.Name, asset.URL)
-			localPath, sum, size, _, err := m.fetchURL(asset.URL)
+			digests, err := pinnedDigests(action.SHA256, action.SHA512, platform)
 			if err != nil {
 				return plan{}, nil, err
 			}
-			pl.targets = append(pl.targets, target)
-			pl.steps = append(pl.steps, func() error {
-				m.Logger.Verbosef("install asset %s -> %s", asset.Name, target)
-				return installFileAtomic(target, localPath, parseMode(action.Mode))
-			})
-			*pl.receiptFiles = append(*pl.receiptFiles, state.ReceiptFile{
-				Path:     manifest.ExpandTemplate(action.Target, ctx),
-				Type:     "file",
-				Mode:     parseMode(action.Mode),
-				SHA256:   sum,
-				Preserve: action.Preserve,
-			})
-			artifacts = append(artifacts, state.Artifact{
-				Type:   "asset",
-				Name:   asset.Name,
-				URL:    asset.URL,
-				SHA256: sum,
-				Size:   size,
-			})
+			for _, asset := range assets {
+				relTarget, err := assetTarget(action, asset, ctx)
+				if err != nil {
+					return plan{}, nil, err
+				}
+				target := filepath.Join(m.Root, relTarget)
+				m.Logger.Infof("download %s %s", asset.Name, asset.URL)
+				localPath, sum, size, _, err := m.fetchAsset(mf, asset, digests)
+				if err != nil {
+					return plan{}, nil, err
+				}
+				if err := m.verifyChecksum(mf, release, platform, ctx, asset, localPath); err != nil {
+					return plan{}, nil, err
+				}
+				if err := m.verifySignature(mf, release, ctx, action.Signature, asset.Name, asset.URL, localPath); err != nil {
+					return plan{}, nil, err
+				}
+				pl.targets = append(pl.targets, target)
+				pl.steps = append(pl.steps, func() error {
+					m.Logger.Verbosef("install asset %s -> %s", asset.Name, target)
+					return installFileAtomic(target, localPath, parseMode(action.Mode))
+				})
+				*pl.receiptFiles = append(*pl.receiptFiles, state.ReceiptFile{
+					Path:     relTarget,
+					Type:     "file",
+					Mode:     parseMode(action.Mode),
+					SHA256:   sum,
+					Preserve: action.Preserve,
+				})
+				artifacts = append(artifacts, state.Artifact{
+					Type:   "asset",
+					Name:   asset.Name,
+					URL:    asset.URL,
+					SHA256: sum,
+					Size:   size,
+					Digest: asset.Digest,
+				})
+			}
 		case "extract":
 			action := *act.Extract
-			installAction, archiveName, skipped, err := m.buildExtractPlan(mf, action, ctx, workDir, pl.receiptFiles)
+			installAction, archiveName, skipped, err := m.buildExtractPlan(mf, release, platform, action, ctx, workDir, pl.receiptFiles)
 			if err != nil {
 				return plan{}, nil, err
 			}
-			targetDir := manifest.ExpandTemplate(action.TargetDir, ctx)
+			targetDir := action.TargetDir
 			m.Logger.Infof("extract %s -> %s", archiveName, targetDir)
 			for _, target := range installAction.targets {
 				m.Logger.Verbosef("extract %s", target)
@@ -158,51 +196,65 @@ func (m *Manager) buildPlan(mf manifest.Manifest, release source.Release, ctx ma
 	return pl, artifacts, nil
 }
 
-func (m *Manager) buildExtractPlan(mf manifest.Manifest, action manifest.ExtractAction, ctx manifest.TemplateContext, workDir string, receiptFiles *[]state.ReceiptFile) (plan, string, []string, error) {
+func (m *Manager) buildExtractPlan(mf manifest.Manifest, release source.Release, platform source.Platform, action manifest.ExtractAction, ctx manifest.TemplateContext, workDir string, receiptFiles *[]state.ReceiptFile) (plan, string, []string, error) {
 	pl := plan{receiptFiles: receiptFiles}
 	sourcePath := ""
 	hintName := ""
+	digests, err := pinnedDigests(action.From.SHA256, action.From.SHA512, platform)
+	if err != nil {
+		return plan{}, "", nil, err
+	}
 	switch action.From.Type {
 	case "asset":
 		assetAction := manifest.AssetAction{
-			Name:    manifest.ExpandTemplate(action.From.Name, ctx),
-			Pattern: manifest.ExpandTemplate(action.From.Pattern, ctx),
+			Name:    action.From.Name,
+			Pattern: action.From.Pattern,
+			Auto:    action.From.Auto,
+			Exclude: action.From.Exclude,
+			Select:  action.From.Select,
 		}
-		resolver, err := source.NewResolver(mf.Source.Kind, m.HTTP)
+		asset, err := source.SelectArchive(release, assetAction, platform)
 		if err != nil {
 			return plan{}, "", nil, err
 		}
-		release, err := resolver.ResolveRelease(mf.Source.Repo, ctx.Tag)
+		m.Logger.Infof("download %s %s", asset.Name, asset.URL)
+		local, _, _, hint, err := m.fetchAsset(mf, asset, digests)
 		if err != nil {
 			return plan{}, "", nil, err
 		}
-		asset, err := source.SelectAsset(release, assetAction)
-		if err != nil {
+		if err := m.verifyChecksum(mf, release, platform, ctx, asset, local); err != nil {
 			return plan{}, "", nil, err
 		}
-		m.Logger.Infof("download %s %s", asset.Name, asset.URL)
-		local, _, _, hint, err := m.fetchURL(asset.URL)
-		if err != nil {
+		if err := m.verifySignature(mf, release, ctx, action.From.Signature, asset.Name, asset.URL, local); err != nil {
 			return plan{}, "", nil, err
 		}
 		sourcePath = local
 		hintName = hint
 	case "url":
-		urlStr := manifest.ExpandTemplate(action.From.URL, ctx)
+		urlStr := action.From.URL
 		m.Logger.Infof("download %s", urlStr)
-		local, _, _, hint, err := m.fetchURL(urlStr)
+		local, _, _, hint, err := m.fetchURL(urlStr, digests)
 		if err != nil {
 			return plan{}, "", nil, err
 		}
+		if err := m.verifySignature(mf, release, ctx, action.From.Signature, "", urlStr, local); err != nil {
+			return plan{}, "", nil, err
+		}
 		sourcePath = local
 		hintName = hint
 	case "file":
-		sourcePath = filepath.Join(mf.PackageDir(), manifest.ExpandTemplate(action.From.Path, ctx))
+		sourcePath = filepath.Join(mf.PackageDir(), action.From.Path)
 		hintName = filepath.Base(sourcePath)
+		if err := checkDigests(sourcePath, action.From.Path, digests); err != nil {
+			return plan{}, "", nil, err
+		}
+		if err := m.verifySignature(mf, release, ctx, action.From.Signature, "", "", sourcePath); err != nil {
+			return plan{}, "", nil, err
+		}
 	default:
 		return plan{}, "", nil, fmt.Errorf("extract.from.type %q is not supported", action.From.Type)
 	}
-	targetDir := filepath.Join(m.Root, manifest.ExpandTemplate(action.TargetDir, ctx))
+	targetDir := filepath.Join(m.Root, action.TargetDir)
 	files, skipped, err := listArchiveFiles(sourcePath, hintName, action)
 	if err != nil {
 		return plan{}, "", nil, err
@@ -223,3 +275,21 @@ func (m *Manager) buildExtractPlan(mf manifest.Manifest, action manifest.Extract
 	})
 	return pl, archiveName, skipped, nil
 }
+
+// assetTarget returns where an (already expanded) asset action installs
+// asset: target, or targetDir joined with targetName (the asset name by
+// default). {asset}, left alone by the first expansion, is the asset name.
+func assetTarget(action manifest.AssetAction, asset source.Asset, ctx manifest.TemplateContext) (string, error) {
+	ctx.Asset = asset.Name
+	if action.TargetDir == "" {
+		return manifest.ExpandTemplate(action.Target, ctx), nil
+	}
+	name := asset.Name
+	if action.TargetName != "" {
+		name = manifest.ExpandTemplate(action.TargetName, ctx)
+	}
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("asset %s: target name %q escapes targetDir", asset.Name, name)
+	}
+	return filepath.Join(action.TargetDir, name), nil
+}
@@ -0,0 +1,106 @@
+package ghpm
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"ghpm/internal/manifest"
+	"ghpm/internal/source"
+)
+
+// platform returns the platform to install for: the host's, or target given
+// as os/arch. It carries the manifest's osMap/archMap overrides, and the libc
+// of the root on Linux.
+func (m *Manager) platform(mf manifest.Manifest, target string) (source.Platform, error) {
+	p := source.Platform{
+		OS:      runtime.GOOS,
+		Arch:    runtime.GOARCH,
+		OSMap:   mf.OSMap,
+		ArchMap: mf.ArchMap,
+	}
+	if target != "" {
+		goos, goarch, ok := strings.Cut(target, "/")
+		if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+			return source.Platform{}, fmt.Errorf("invalid platform %q (expected os/arch)", target)
+		}
+		p.OS, p.Arch = goos, goarch
+	}
+	if p.OS == "linux" {
+		p.Libc = detectLibc(m.Root)
+	}
+	return p, nil
+}
+
+// detectLibc reports "musl" when the root has a musl dynamic loader (Alpine
+// and friends), "gnu" otherwise.
+func detectLibc(root string) string {
+	for _, dir := range []string{"lib", "usr/lib"} {
+		if matches, _ := filepath.Glob(filepath.Join(root, dir, "ld-musl-*.so.1")); len(matches) > 0 {
+			return "musl"
+		}
+	}
+	return "gnu"
+}
+
+// facts returns what action when: conditions are evaluated against. Distro
+// and hostname are read under the root.
+func (m *Manager) facts(p source.Platform) manifest.Facts {
+	return manifest.Facts{
+		OS:       p.OS,
+		Arch:     p.Arch,
+		Libc:     p.Libc,
+		Distro:   readDistroIDs(m.Root),
+		Hostname: readHostname(m.Root),
+	}
+}
+
+// readDistroIDs returns ID followed by the ID_LIKE entries of os-release.
+func readDistroIDs(root string) []string {
+	var f *os.File
+	var err error
+	for _, name := range []string{"etc/os-release", "usr/lib/os-release"} {
+		if f, err = os.Open(filepath.Join(root, name)); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var id string
+	var like []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			id = value
+		case "ID_LIKE":
+			like = strings.Fields(value)
+		}
+	}
+	if id == "" {
+		return like
+	}
+	return append([]string{id}, like...)
+}
+
+func readHostname(root string) string {
+	if root == "" || root == "/" {
+		name, _ := os.Hostname()
+		return name
+	}
+	data, err := os.ReadFile(filepath.Join(root, "etc", "hostname"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,273 @@
+package ghpm
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"ghpm/internal/manifest"
+	"ghpm/internal/signature"
+	"ghpm/internal/source"
+)
+
+// VerificationError reports a download whose content does not match what
+// upstream or the manifest says it should be.
+type VerificationError struct {
+	Name   string
+	Reason string
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("verification failed for %s: %s", e.Name, e.Reason)
+}
+
+// verifyChecksum checks a downloaded release asset against the manifest's
+// checksums asset, if any. The checksums asset name and pattern may use
+// {asset} for per-asset files such as {asset}.sha256.
+func (m *Manager) verifyChecksum(mf manifest.Manifest, release source.Release, platform source.Platform, ctx manifest.TemplateContext, asset source.Asset, localPath string) error {
+	if mf.Checksums == nil {
+		return nil
+	}
+	ctx.Asset = asset.Name
+	action := manifest.AssetAction{
+		Name:    manifest.ExpandTemplate(mf.Checksums.Name, ctx),
+		Pattern: manifest.ExpandTemplate(mf.Checksums.Pattern, ctx),
+	}
+	sumsAsset, err := source.SelectAsset(release, action, platform)
+	if err != nil {
+		return &VerificationError{Name: asset.Name, Reason: fmt.Sprintf("checksums: %v", err)}
+	}
+	if sumsAsset.Name == asset.Name {
+		return nil
+	}
+	sumsPath, _, _, _, err := m.fetchAsset(mf, sumsAsset, nil)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(sumsPath)
+	if err != nil {
+		return err
+	}
+	sums := parseChecksums(data)
+	expected, ok := sums[asset.Name]
+	if !ok {
+		expected, ok = sums[""]
+	}
+	if !ok {
+		return &VerificationError{Name: asset.Name, Reason: fmt.Sprintf("not listed in %s", sumsAsset.Name)}
+	}
+	algo, actual, err := fileDigest(localPath, expected)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, expected) {
+		return &VerificationError{Name: asset.Name, Reason: fmt.Sprintf("%s is %s, %s says %s", algo, actual, sumsAsset.Name, expected)}
+	}
+	m.Logger.Verbosef("verified %s against %s (%s)", asset.Name, sumsAsset.Name, algo)
+	return nil
+}
+
+// parseChecksums reads GNU (`<hex>  <name>`, `<hex> *<name>`) and BSD
+// (`SHA256 (<name>) = <hex>`) checksum lines, keyed by file base name. A file
+// holding only a digest, as per-asset .sha256 files often do, is keyed "".
+func parseChecksums(data []byte) map[string]string {
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if open := strings.Index(line, " ("); open > 0 {
+			if rest, digest, ok := strings.Cut(line[open+2:], ") = "); ok && isHexDigest(digest) {
+				sums[path.Base(rest)] = digest
+				continue
+			}
+		}
+		fields := strings.Fields(line)
+		if !isHexDigest(fields[0]) {
+			continue
+		}
+		name := ""
+		if len(fields) > 1 {
+			name = path.Base(strings.TrimPrefix(strings.Join(fields[1:], " "), "*"))
+		}
+		sums[name] = fields[0]
+	}
+	return sums
+}
+
+func isHexDigest(s string) bool {
+	if len(s) != 64 && len(s) != 128 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// fileDigest hashes a file with the algorithm matching the length of the
+// expected hex digest: sha256 or sha512.
+func fileDigest(localPath string, expected string) (string, string, error) {
+	algo, h := "sha256", hash.Hash(sha256.New())
+	if len(expected) == 128 {
+		algo, h = "sha512", sha512.New()
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", "", err
+	}
+	return algo, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// pinnedDigests returns the digests an action pins for the platform, as
+// "algo:hex" strings.
+func pinnedDigests(sha256, sha512 manifest.Digests, platform source.Platform) ([]string, error) {
+	var digests []string
+	for _, pin := range []struct {
+		algo    string
+		digests manifest.Digests
+	}{{"sha256", sha256}, {"sha512", sha512}} {
+		digest, ok := pin.digests.For(platform.String())
+		if !ok {
+			return nil, &VerificationError{Name: platform.String(), Reason: fmt.Sprintf("no %s pinned for this platform", pin.algo)}
+		}
+		if digest != "" {
+			digests = append(digests, pin.algo+":"+strings.ToLower(digest))
+		}
+	}
+	return digests, nil
+}
+
+// checkDigests verifies a file against "algo:hex" digests.
+func checkDigests(localPath, name string, digests []string) error {
+	for _, digest := range digests {
+		algo, expected, _ := strings.Cut(digest, ":")
+		actualAlgo, actual, err := fileDigest(localPath, expected)
+		if err != nil {
+			return err
+		}
+		if actualAlgo != algo {
+			return fmt.Errorf("%s: unsupported digest %s", name, digest)
+		}
+		if !strings.EqualFold(actual, expected) {
+			return &VerificationError{Name: name, Reason: fmt.Sprintf("%s is %s, expected %s", algo, actual, expected)}
+		}
+	}
+	return nil
+}
+
+// supportedDigest reports whether an upstream "algo:hex" digest can be
+// checked; other algorithms are ignored.
+func supportedDigest(digest string) bool {
+	algo, value, ok := strings.Cut(digest, ":")
+	if !ok || !isHexDigest(value) {
+		return false
+	}
+	return algo == "sha256" && len(value) == 64 || algo == "sha512" && len(value) == 128
+}
+
+// verifySignature checks a download against a detached minisign or signify
+// signature. assetName is the release asset downloaded, empty for URLs and
+// local files. The signature is the asset or URL the block names, by default
+// <asset>.minisig or <url>.minisig.
+func (m *Manager) verifySignature(mf manifest.Manifest, release source.Release, ctx manifest.TemplateContext, sig *manifest.Signature, assetName, urlStr, localPath string) error {
+	if sig == nil {
+		return nil
+	}
+	label := assetName
+	if label == "" {
+		label = urlStr
+	}
+	if label == "" {
+		label = filepath.Base(localPath)
+	}
+	keys, err := m.signatureKeys(sig)
+	if err != nil {
+		return err
+	}
+	ctx.Asset = assetName
+	var sigPath string
+	switch {
+	case sig.URL != "":
+		sigPath, _, _, _, err = m.fetchURL(manifest.ExpandTemplate(sig.URL, ctx), nil)
+	case sig.Name != "" || assetName != "":
+		name := manifest.ExpandTemplate(sig.Name, ctx)
+		if name == "" {
+			name = assetName + ".minisig"
+		}
+		var sigAsset source.Asset
+		sigAsset, err = source.SelectAsset(release, manifest.AssetAction{Name: name}, source.Platform{})
+		if err != nil {
+			return &VerificationError{Name: label, Reason: fmt.Sprintf("signature: %v", err)}
+		}
+		sigPath, _, _, _, err = m.fetchAsset(mf, sigAsset, nil)
+	case urlStr != "":
+		sigPath, _, _, _, err = m.fetchURL(urlStr+".minisig", nil)
+	default:
+		return fmt.Errorf("%s: signature.url is required for local files", label)
+	}
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(sigPath)
+	if err != nil {
+		return err
+	}
+	parsed, err := signature.ParseSignature(data)
+	if err != nil {
+		return &VerificationError{Name: label, Reason: err.Error()}
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := signature.Verify(f, parsed, keys); err != nil {
+		return &VerificationError{Name: label, Reason: err.Error()}
+	}
+	m.Logger.Verbosef("verified signature of %s", label)
+	return nil
+}
+
+// signatureKeys returns the trusted keys of a signature block: embedded ones
+// and key files, relative to the keys directory. Like every other path, key
+// files are read from the install root.
+func (m *Manager) signatureKeys(sig *manifest.Signature) ([]signature.PublicKey, error) {
+	var keys []signature.PublicKey
+	for _, text := range sig.Keys {
+		key, err := signature.ParsePublicKey(text)
+		if err != nil {
+			return nil, fmt.Errorf("signature.keys: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	for _, name := range sig.KeyFiles {
+		keyPath := filepath.Join(m.Root, name)
+		if !filepath.IsAbs(name) {
+			keyPath = filepath.Join(m.KeysDir(), name)
+		}
+		data, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		key, err := signature.ParsePublicKey(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", keyPath, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
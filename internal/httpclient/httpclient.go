@@ -0,0 +1,194 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	baseBackoff  = 500 * time.Millisecond
+	maxBackoff   = 30 * time.Second
+	maxRetryWait = time.Minute
+)
+
+// RateLimitError reports a request rejected by an API rate limit whose reset
+// is too far away to wait for.
+type RateLimitError struct {
+	What  string
+	Until time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Until.IsZero() {
+		return fmt.Sprintf("%s: rate limited", e.What)
+	}
+	return fmt.Sprintf("%s: rate limited until %s", e.What, e.Until.Local().Format("15:04"))
+}
+
+// StatusError describes a non-successful response, turning rate limit
+// rejections into a RateLimitError.
+func StatusError(resp *http.Response, what string) error {
+	if isRateLimited(resp) {
+		until, _ := retryAt(resp, time.Now())
+		return &RateLimitError{What: what, Until: until}
+	}
+	return fmt.Errorf("%s: %s", what, resp.Status)
+}
+
+// Transport retries idempotent requests on connection errors, 5xx responses
+// and rate limits. Each attempt gets its own timeout so that backoff delays
+// do not eat into it.
+type Transport struct {
+	Base    http.RoundTripper
+	Retries int
+	Timeout time.Duration
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := t.roundTrip(base, req)
+		if attempt >= t.Retries || !canRetry(req) {
+			return resp, err
+		}
+		var wait time.Duration
+		if err != nil {
+			if !isTransient(err) {
+				return nil, err
+			}
+			wait = backoff(attempt)
+		} else {
+			if !isRateLimited(resp) && resp.StatusCode < 500 {
+				return resp, nil
+			}
+			wait = backoff(attempt)
+			if until, ok := retryAt(resp, time.Now()); ok {
+				wait = time.Until(until)
+			}
+			if wait > maxRetryWait {
+				return resp, nil
+			}
+			resp.Body.Close()
+		}
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+		if req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+func (t *Transport) roundTrip(base http.RoundTripper, req *http.Request) (*http.Response, error) {
+	if t.Timeout <= 0 {
+		return base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.Timeout)
+	resp, err := base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func canRetry(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return req.Body == nil || req.GetBody != nil
+	}
+	return false
+}
+
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func isRateLimited(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != ""
+	}
+	return false
+}
+
+// retryAt derives when a rejected request may be retried from Retry-After
+// (seconds or HTTP date) or, once the quota is exhausted, X-RateLimit-Reset.
+func retryAt(resp *http.Response, now time.Time) (time.Time, bool) {
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return now.Add(time.Duration(secs) * time.Second), true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return t, true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if secs, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return time.Unix(secs, 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// backoff returns an exponential delay with full jitter on its upper half.
+func backoff(attempt int) time.Duration {
+	d := baseBackoff << attempt
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,106 @@
+package manifest
+
+import (
+	"fmt"
+	"path"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Condition restricts an action to some hosts. Each field lists accepted
+// values (a single value is accepted as well); all set fields must match.
+type Condition struct {
+	OS       StringList `yaml:"os"`
+	Arch     StringList `yaml:"arch"`
+	Libc     StringList `yaml:"libc"`
+	Distro   StringList `yaml:"distro"`
+	Hostname StringList `yaml:"hostname"`
+}
+
+// StringList is a YAML sequence of strings that may be written as a scalar.
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = StringList{value.Value}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// Facts describe the host a condition is evaluated against.
+type Facts struct {
+	OS   string
+	Arch string
+	Libc string
+	// Distro holds ID and ID_LIKE from os-release.
+	Distro   []string
+	Hostname string
+}
+
+// Matches reports whether the facts satisfy every field of the condition.
+// Hostnames are shell globs.
+func (c Condition) Matches(f Facts) bool {
+	if len(c.OS) > 0 && !containsString(c.OS, f.OS) {
+		return false
+	}
+	if len(c.Arch) > 0 && !containsString(c.Arch, f.Arch) {
+		return false
+	}
+	if len(c.Libc) > 0 && !containsString(c.Libc, f.Libc) {
+		return false
+	}
+	if len(c.Distro) > 0 {
+		found := false
+		for _, id := range f.Distro {
+			if containsString(c.Distro, id) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(c.Hostname) > 0 {
+		found := false
+		for _, pattern := range c.Hostname {
+			if ok, _ := path.Match(pattern, f.Hostname); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Condition) validate() error {
+	for _, pattern := range c.Hostname {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("hostname %q: %w", pattern, err)
+		}
+	}
+	for _, libc := range c.Libc {
+		if libc != "gnu" && libc != "musl" {
+			return fmt.Errorf("libc %q is unsupported (gnu or musl)", libc)
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package manifest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Digests is an expected hex digest, either a single value or a map from
+// os/arch to value for actions whose content differs per platform.
+type Digests map[string]string
+
+func (d *Digests) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*d = Digests{"": value.Value}
+		return nil
+	}
+	var m map[string]string
+	if err := value.Decode(&m); err != nil {
+		return err
+	}
+	*d = m
+	return nil
+}
+
+// For returns the digest for platform (os/arch). ok is false when digests
+// are set but none applies to the platform.
+func (d Digests) For(platform string) (digest string, ok bool) {
+	if len(d) == 0 {
+		return "", true
+	}
+	if v, found := d[platform]; found {
+		return v, true
+	}
+	v, found := d[""]
+	return v, found
+}
+
+func (d Digests) validate(size int) error {
+	keys := make([]string, 0, len(d))
+	for key := range d {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key != "" && strings.Count(key, "/") != 1 {
+			return fmt.Errorf("platform %q (expected os/arch)", key)
+		}
+		if b, err := hex.DecodeString(d[key]); err != nil || len(b) != size {
+			return fmt.Errorf("%q is not a %d-byte hex digest", d[key], size)
+		}
+	}
+	return nil
+}
@@ -12,18 +12,44 @@ import (
 )
 
 type Manifest struct {
-	Name        string   `yaml:"name"`
-	Description string   `yaml:"description"`
-	Source      Source   `yaml:"source"`
-	Install     []Action `yaml:"install"`
-	PostInstall []string `yaml:"postInstall"`
-	PostRemove  []string `yaml:"postRemove"`
-	Path        string   `yaml:"-"`
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Version     string            `yaml:"version"`
+	Source      Source            `yaml:"source"`
+	Checksums   *Checksums        `yaml:"checksums"`
+	Install     []Action          `yaml:"install"`
+	PostInstall []string          `yaml:"postInstall"`
+	PostRemove  []string          `yaml:"postRemove"`
+	OSMap       map[string]string `yaml:"osMap"`
+	ArchMap     map[string]string `yaml:"archMap"`
+	Path        string            `yaml:"-"`
 }
 
 type Source struct {
-	Kind string `yaml:"kind"`
-	Repo string `yaml:"repo"`
+	Kind           string     `yaml:"kind"`
+	Repo           string     `yaml:"repo"`
+	Host           string     `yaml:"host"`
+	APIURL         string     `yaml:"apiURL"`
+	Private        bool       `yaml:"private"`
+	Channel        string     `yaml:"channel"`
+	VersionScheme  string     `yaml:"versionScheme"`
+	TagPattern     string     `yaml:"tagPattern"`
+	VersionPattern string     `yaml:"versionPattern"`
+	Discovery      *Discovery `yaml:"discovery"`
+	From           string     `yaml:"from"`
+	Latest         string     `yaml:"latest"`
+}
+
+type Checksums struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+}
+
+type Discovery struct {
+	Type    string `yaml:"type"`
+	URL     string `yaml:"url"`
+	Path    string `yaml:"path"`
+	Pattern string `yaml:"pattern"`
 }
 
 type Action struct {
@@ -34,22 +60,34 @@ type Action struct {
 	Symlink *SymlinkAction
 	Extract *ExtractAction
 	Mkdir   *MkdirAction
+	When    *Condition
 	Raw     map[string]any
 }
 
 type AssetAction struct {
-	Name     string `yaml:"name"`
-	Pattern  string `yaml:"pattern"`
-	Target   string `yaml:"target"`
-	Mode     string `yaml:"mode"`
-	Preserve bool   `yaml:"preserve"`
+	Name       string     `yaml:"name"`
+	Pattern    string     `yaml:"pattern"`
+	Auto       bool       `yaml:"auto"`
+	Exclude    []string   `yaml:"exclude"`
+	Select     string     `yaml:"select"`
+	Target     string     `yaml:"target"`
+	TargetDir  string     `yaml:"targetDir"`
+	TargetName string     `yaml:"targetName"`
+	Mode       string     `yaml:"mode"`
+	Preserve   bool       `yaml:"preserve"`
+	SHA256     Digests    `yaml:"sha256"`
+	SHA512     Digests    `yaml:"sha512"`
+	Signature  *Signature `yaml:"signature"`
 }
 
 type URLAction struct {
-	URL      string `yaml:"url"`
-	Target   string `yaml:"target"`
-	Mode     string `yaml:"mode"`
-	Preserve bool   `yaml:"preserve"`
+	URL       string     `yaml:"url"`
+	Target    string     `yaml:"target"`
+	Mode      string     `yaml:"mode"`
+	Preserve  bool       `yaml:"preserve"`
+	SHA256    Digests    `yaml:"sha256"`
+	SHA512    Digests    `yaml:"sha512"`
+	Signature *Signature `yaml:"signature"`
 }
 
 type FileAction struct {
@@ -74,11 +112,24 @@ type ExtractAction struct {
 }
 
 type ExtractFrom struct {
-	Type    string `yaml:"type"`
-	Name    string `yaml:"name"`
-	Pattern string `yaml:"pattern"`
-	URL     string `yaml:"url"`
-	Path    string `yaml:"path"`
+	Type      string     `yaml:"type"`
+	Name      string     `yaml:"name"`
+	Pattern   string     `yaml:"pattern"`
+	Auto      bool       `yaml:"auto"`
+	Exclude   []string   `yaml:"exclude"`
+	Select    string     `yaml:"select"`
+	URL       string     `yaml:"url"`
+	Path      string     `yaml:"path"`
+	SHA256    Digests    `yaml:"sha256"`
+	SHA512    Digests    `yaml:"sha512"`
+	Signature *Signature `yaml:"signature"`
+}
+
+type Signature struct {
+	Name     string   `yaml:"name"`
+	URL      string   `yaml:"url"`
+	Keys     []string `yaml:"keys"`
+	KeyFiles []string `yaml:"keyFiles"`
 }
 
 type MkdirAction struct {
@@ -98,6 +149,10 @@ func Load(path string) (Manifest, error) {
 		return Manifest{}, fmt.Errorf("%s: %w", path, err)
 	}
 	m.Path = path
+	if kind, ok := strings.CutSuffix(m.Source.Kind, "-tags"); ok && m.Source.From == "" {
+		m.Source.Kind = kind
+		m.Source.From = "tags"
+	}
 	if m.Name == "" {
 		m.Name = filepath.Base(filepath.Dir(path))
 	}
@@ -115,20 +170,102 @@ func (m Manifest) Validate() error {
 	if m.Name == "" {
 		return errors.New("manifest name is required")
 	}
+	switch m.Source.From {
+	case "", "releases":
+	case "tags":
+		if m.Source.Kind != "github" && m.Source.Kind != "gitlab" {
+			return fmt.Errorf("source.from: tags is not supported for kind %s", m.Source.Kind)
+		}
+	case "feed":
+		if m.Source.Kind != "github" {
+			return fmt.Errorf("source.from: feed is not supported for kind %s", m.Source.Kind)
+		}
+	default:
+		return fmt.Errorf("source.from %q is unsupported", m.Source.From)
+	}
+	switch m.Source.Latest {
+	case "", "version":
+	case "upstream":
+		if m.Source.Kind != "github" || m.Source.From == "tags" {
+			return errors.New("source.latest: upstream is only supported for github releases")
+		}
+	default:
+		return fmt.Errorf("source.latest %q is unsupported", m.Source.Latest)
+	}
+	if d := m.Source.Discovery; d != nil {
+		if m.Source.Kind != "http" {
+			return errors.New("source.discovery is only supported for kind http")
+		}
+		if d.URL == "" {
+			return errors.New("source.discovery.url is required")
+		}
+		switch d.Type {
+		case "json":
+			if d.Path == "" {
+				return errors.New("source.discovery.path is required for json discovery")
+			}
+		case "html":
+			if d.Pattern == "" {
+				return errors.New("source.discovery.pattern is required for html discovery")
+			}
+		case "text":
+		default:
+			return fmt.Errorf("source.discovery.type %q is unsupported", d.Type)
+		}
+	}
+	if m.Checksums != nil && m.Checksums.Name == "" && m.Checksums.Pattern == "" {
+		return errors.New("checksums.name or pattern is required")
+	}
+	if m.Checksums != nil {
+		for _, field := range []string{m.Checksums.Name, m.Checksums.Pattern} {
+			if err := validateTemplate(field); err != nil {
+				return fmt.Errorf("checksums: %w", err)
+			}
+		}
+	}
+	for i, hook := range m.PostInstall {
+		if err := validateTemplate(hook); err != nil {
+			return fmt.Errorf("postInstall[%d]: %w", i, err)
+		}
+	}
 	for i, action := range m.Install {
 		if action.Type == "" {
 			return fmt.Errorf("install[%d].type is required", i)
 		}
+		if action.When != nil {
+			if err := action.When.validate(); err != nil {
+				return fmt.Errorf("install[%d].when.%w", i, err)
+			}
+		}
+		if err := validateDigests(action); err != nil {
+			return fmt.Errorf("install[%d].%w", i, err)
+		}
+		if err := validateSignature(action); err != nil {
+			return fmt.Errorf("install[%d].%w", i, err)
+		}
+		if err := action.validateTemplates(); err != nil {
+			return fmt.Errorf("install[%d]: %w", i, err)
+		}
 		switch action.Type {
 		case "asset":
 			if action.Asset == nil {
 				return fmt.Errorf("install[%d].asset is required", i)
 			}
-			if action.Asset.Name == "" && action.Asset.Pattern == "" {
-				return fmt.Errorf("install[%d].asset.name or pattern is required", i)
+			if action.Asset.Name == "" && action.Asset.Pattern == "" && !action.Asset.Auto {
+				return fmt.Errorf("install[%d].asset.name, pattern or auto is required", i)
+			}
+			switch {
+			case action.Asset.Target == "" && action.Asset.TargetDir == "":
+				return fmt.Errorf("install[%d].asset.target or targetDir is required", i)
+			case action.Asset.Target != "" && action.Asset.TargetDir != "":
+				return fmt.Errorf("install[%d].asset.target and targetDir are exclusive", i)
+			case action.Asset.TargetDir != "" && action.Asset.Pattern == "":
+				return fmt.Errorf("install[%d].asset.targetDir requires pattern", i)
+			case action.Asset.TargetDir != "" && (len(action.Asset.SHA256) > 0 || len(action.Asset.SHA512) > 0):
+				return fmt.Errorf("install[%d].asset.sha256 and sha512 cannot pin several assets", i)
 			}
-			if action.Asset.Target == "" {
-				return fmt.Errorf("install[%d].asset.target is required", i)
+			if !validSelect(action.Asset.Select) {
+				return fmt.Errorf("install[%d].asset.select %q is unsupported", i, action.Asset.Select)
 			}
 		case "url":
 			if action.URL == nil {
@@ -166,8 +303,11 @@ func (m Manifest) Validate() error {
 			}
 			switch action.Extract.From.Type {
 			case "asset":
-				if action.Extract.From.Name == "" && action.Extract.From.Pattern == "" {
-					return fmt.Errorf("install[%d].extract.from.name or pattern is required", i)
+				if action.Extract.From.Name == "" && action.Extract.From.Pattern == "" && !action.Extract.From.Auto {
+					return fmt.Errorf("install[%d].extract.from.name, pattern or auto is required", i)
+				}
+				if !validSelect(action.Extract.From.Select) {
+					return fmt.Errorf("install[%d].extract.from.select %q is unsupported", i, action.Extract.From.Select)
 				}
 			case "url":
 				if action.Extract.From.URL == "" {
@@ -195,6 +335,62 @@ func (m Manifest) Validate() error {
 	return nil
 }
 
+func validateDigests(action Action) error {
+	var prefix string
+	var sha256, sha512 Digests
+	switch {
+	case action.Asset != nil:
+		prefix, sha256, sha512 = "asset", action.Asset.SHA256, action.Asset.SHA512
+	case action.URL != nil:
+		prefix, sha256, sha512 = "url", action.URL.SHA256, action.URL.SHA512
+	case action.Extract != nil:
+		prefix, sha256, sha512 = "extract.from", action.Extract.From.SHA256, action.Extract.From.SHA512
+	default:
+		return nil
+	}
+	if err := sha256.validate(32); err != nil {
+		return fmt.Errorf("%s.sha256: %w", prefix, err)
+	}
+	if err := sha512.validate(64); err != nil {
+		return fmt.Errorf("%s.sha512: %w", prefix, err)
+	}
+	return nil
+}
+
+func validateSignature(action Action) error {
+	var prefix string
+	var sig *Signature
+	switch {
+	case action.Asset != nil:
+		prefix, sig = "asset", action.Asset.Signature
+	case action.URL != nil:
+		prefix, sig = "url", action.URL.Signature
+	case action.Extract != nil:
+		prefix, sig = "extract.from", action.Extract.From.Signature
+	}
+	if sig == nil {
+		return nil
+	}
+	if len(sig.Keys) == 0 && len(sig.KeyFiles) == 0 {
+		return fmt.Errorf("%s.signature.keys or keyFiles is required", prefix)
+	}
+	if sig.Name != "" && sig.URL != "" {
+		return fmt.Errorf("%s.signature.name and url are exclusive", prefix)
+	}
+	if action.Extract != nil && action.Extract.From.Type == "file" && sig.URL == "" {
+		return fmt.Errorf("%s.signature.url is required for local files", prefix)
+	}
+	return nil
+}
+
+func validSelect(strategy string) bool {
+	switch strategy {
+	case "", "first", "largest", "newest":
+		return true
+	}
+	return false
+}
+
 func (a *Action) UnmarshalYAML(value *yaml.Node) error {
 	if value.Kind != yaml.MappingNode {
 		return fmt.Errorf("action must be a mapping (line %d)", value.Line)
@@ -209,6 +405,13 @@ func (a *Action) UnmarshalYAML(value *yaml.Node) error {
 	}
 	a.Type = typ
 	a.Raw = raw
+	var common struct {
+		When *Condition `yaml:"when"`
+	}
+	if err := value.Decode(&common); err != nil {
+		return fmt.Errorf("invalid action when (line %d): %w", value.Line, err)
+	}
+	a.When = common.When
 	switch typ {
 	case "asset":
 		var v AssetAction
@@ -252,27 +455,6 @@ func (a *Action) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
-type TemplateContext struct {
-	Version string
-	Tag     string
-	OS      string
-	Arch    string
-	Repo    string
-	Name    string
-}
-
-func ExpandTemplate(input string, ctx TemplateContext) string {
-	replacer := strings.NewReplacer(
-		"{version}", ctx.Version,
-		"{tag}", ctx.Tag,
-		"{os}", ctx.OS,
-		"{arch}", ctx.Arch,
-		"{repo}", ctx.Repo,
-		"{name}", ctx.Name,
-	)
-	return replacer.Replace(input)
-}
-
 func MatchPattern(name, pattern string) bool {
 	if pattern == "" {
 		return false
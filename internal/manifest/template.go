@@ -0,0 +1,255 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type TemplateContext struct {
+	Version string
+	Tag     string
+	OS      string
+	Arch    string
+	Repo    string
+	Name    string
+	// Asset is the asset being installed; {asset} is left as is while empty
+	// so that per-asset fields can be expanded later.
+	Asset string
+}
+
+// ExpandTemplate replaces {expr} placeholders in input. An expression is a
+// variable (version, tag, os, arch, repo, name, asset or env.NAME) followed by
+// filters, e.g. {version|trimprefix:v} or {arch|map:amd64=x86_64}. Anything
+// else between braces, like regex quantifiers, is kept literally. Manifests
+// are validated on load, so a known variable never has an invalid filter.
+func ExpandTemplate(input string, ctx TemplateContext) string {
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(input, '{')
+		if open < 0 {
+			b.WriteString(input)
+			return b.String()
+		}
+		end := strings.IndexByte(input[open:], '}')
+		if end < 0 {
+			b.WriteString(input)
+			return b.String()
+		}
+		end += open
+		if value, ok, err := ctx.eval(input[open+1 : end]); ok && err == nil {
+			b.WriteString(input[:open])
+			b.WriteString(value)
+			input = input[end+1:]
+			continue
+		}
+		b.WriteString(input[:open+1])
+		input = input[open+1:]
+	}
+}
+
+// validateTemplate reports placeholders of known variables with unknown or
+// malformed filters, which would otherwise be left in the expanded text.
+func validateTemplate(input string) error {
+	ctx := TemplateContext{Asset: "asset"}
+	for {
+		open := strings.IndexByte(input, '{')
+		if open < 0 {
+			return nil
+		}
+		end := strings.IndexByte(input[open:], '}')
+		if end < 0 {
+			return nil
+		}
+		end += open
+		expr := input[open+1 : end]
+		_, ok, err := ctx.eval(expr)
+		if err != nil {
+			return fmt.Errorf("template {%s}: %w", expr, err)
+		}
+		if ok {
+			input = input[end+1:]
+		} else {
+			input = input[open+1:]
+		}
+	}
+}
+
+// eval evaluates a placeholder expression. ok is false when it does not start
+// with a known variable (or {asset} is not set yet); err reports a filter that
+// does not apply.
+func (ctx TemplateContext) eval(expr string) (string, bool, error) {
+	parts := strings.Split(expr, "|")
+	value, ok := ctx.lookup(parts[0])
+	if !ok {
+		return "", false, nil
+	}
+	for _, filter := range parts[1:] {
+		name, arg, _ := strings.Cut(filter, ":")
+		var err error
+		value, err = applyFilter(name, arg, value)
+		if err != nil {
+			return "", true, err
+		}
+	}
+	return value, true, nil
+}
+
+func (ctx TemplateContext) lookup(name string) (string, bool) {
+	switch name {
+	case "version":
+		return ctx.Version, true
+	case "tag":
+		return ctx.Tag, true
+	case "os":
+		return ctx.OS, true
+	case "arch":
+		return ctx.Arch, true
+	case "repo":
+		return ctx.Repo, true
+	case "name":
+		return ctx.Name, true
+	case "asset":
+		return ctx.Asset, ctx.Asset != ""
+	}
+	if env, ok := strings.CutPrefix(name, "env."); ok && env != "" {
+		return os.Getenv(env), true
+	}
+	return "", false
+}
+
+func applyFilter(name, arg, value string) (string, error) {
+	switch name {
+	case "trimprefix":
+		return strings.TrimPrefix(value, arg), nil
+	case "trimsuffix":
+		return strings.TrimSuffix(value, arg), nil
+	case "replace":
+		old, new, ok := strings.Cut(arg, "=")
+		if !ok {
+			return "", fmt.Errorf("replace needs old=new, got %q", arg)
+		}
+		return strings.ReplaceAll(value, old, new), nil
+	case "map":
+		// Every pair is checked, so that validation catches malformed ones.
+		mapped, found := value, false
+		for _, pair := range strings.Split(arg, ",") {
+			from, to, ok := strings.Cut(pair, "=")
+			if !ok {
+				return "", fmt.Errorf("map needs from=to pairs, got %q", pair)
+			}
+			if from == value && !found {
+				mapped, found = to, true
+			}
+		}
+		return mapped, nil
+	case "upper":
+		return strings.ToUpper(value), nil
+	case "lower":
+		return strings.ToLower(value), nil
+	case "title":
+		if value == "" {
+			return value, nil
+		}
+		return strings.ToUpper(value[:1]) + value[1:], nil
+	case "default":
+		if value == "" {
+			return arg, nil
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown filter %q", name)
+}
+
+// ExpandStrings expands every element of values.
+func ExpandStrings(values []string, ctx TemplateContext) []string {
+	return mapStrings(values, func(s string) string { return ExpandTemplate(s, ctx) })
+}
+
+func mapStrings(values []string, f func(string) string) []string {
+	if values == nil {
+		return nil
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = f(v)
+	}
+	return out
+}
+
+// Expand returns a copy of the action with templates expanded in all of its
+// string fields.
+func (a Action) Expand(ctx TemplateContext) Action {
+	return a.mapFields(func(s string) string { return ExpandTemplate(s, ctx) })
+}
+
+// validateTemplates checks the templates of all of the action's string
+// fields.
+func (a Action) validateTemplates() error {
+	var err error
+	a.mapFields(func(s string) string {
+		if err == nil {
+			err = validateTemplate(s)
+		}
+		return s
+	})
+	return err
+}
+
+// mapFields returns a copy of the action with x applied to all of its string
+// fields.
+func (a Action) mapFields(x func(string) string) Action {
+	if a.Asset != nil {
+		v := *a.Asset
+		v.Name, v.Pattern, v.Select = x(v.Name), x(v.Pattern), x(v.Select)
+		v.Exclude = mapStrings(v.Exclude, x)
+		v.Target, v.TargetDir, v.TargetName = x(v.Target), x(v.TargetDir), x(v.TargetName)
+		v.Mode = x(v.Mode)
+		v.Signature = v.Signature.mapFields(x)
+		a.Asset = &v
+	}
+	if a.URL != nil {
+		v := *a.URL
+		v.URL, v.Target, v.Mode = x(v.URL), x(v.Target), x(v.Mode)
+		v.Signature = v.Signature.mapFields(x)
+		a.URL = &v
+	}
+	if a.File != nil {
+		v := *a.File
+		v.Path, v.Target, v.Mode = x(v.Path), x(v.Target), x(v.Mode)
+		a.File = &v
+	}
+	if a.Symlink != nil {
+		v := *a.Symlink
+		v.Target, v.To = x(v.Target), x(v.To)
+		a.Symlink = &v
+	}
+	if a.Extract != nil {
+		v := *a.Extract
+		v.From.Name, v.From.Pattern, v.From.Select = x(v.From.Name), x(v.From.Pattern), x(v.From.Select)
+		v.From.Exclude = mapStrings(v.From.Exclude, x)
+		v.From.URL, v.From.Path = x(v.From.URL), x(v.From.Path)
+		v.Format, v.TargetDir = x(v.Format), x(v.TargetDir)
+		v.Pick = mapStrings(v.Pick, x)
+		v.Omit = mapStrings(v.Omit, x)
+		v.From.Signature = v.From.Signature.mapFields(x)
+		a.Extract = &v
+	}
+	if a.Mkdir != nil {
+		v := *a.Mkdir
+		v.Path, v.Mode, v.Owner, v.Group = x(v.Path), x(v.Mode), x(v.Owner), x(v.Group)
+		a.Mkdir = &v
+	}
+	return a
+}
+
+func (s *Signature) mapFields(x func(string) string) *Signature {
+	if s == nil {
+		return nil
+	}
+	v := *s
+	v.Name = x(v.Name)
+	v.URL = x(v.URL)
+	v.KeyFiles = mapStrings(v.KeyFiles, x)
+	return &v
+}
@@ -0,0 +1,147 @@
+// Package signature verifies minisign and signify detached signatures.
+//
+// Both tools share the same Ed25519 key and signature encoding: a base64
+// line holding a 2-byte algorithm, an 8-byte key ID and the key or
+// signature, preceded by an "untrusted comment:" line. Minisign adds a
+// trusted comment and a global signature covering it, and its "ED"
+// algorithm signs the BLAKE2b-512 hash of the file instead of the file.
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	algLegacy    = "Ed"
+	algPrehashed = "ED"
+)
+
+type PublicKey struct {
+	ID  [8]byte
+	Key ed25519.PublicKey
+}
+
+type Signature struct {
+	Algorithm      string
+	KeyID          [8]byte
+	Signature      []byte
+	TrustedComment string
+	// GlobalSignature signs Signature and TrustedComment (minisign only).
+	GlobalSignature []byte
+}
+
+// ParsePublicKey parses a public key given either as its base64 line (as
+// printed by minisign -G or embedded in manifests) or as a key file.
+func ParsePublicKey(text string) (PublicKey, error) {
+	var line string
+	for _, l := range strings.Split(strings.TrimSpace(text), "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "untrusted comment:") {
+			line = l
+		}
+	}
+	raw, err := base64.StdEncoding.DecodeString(line)
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != algLegacy {
+		return PublicKey{}, errors.New("invalid minisign/signify public key")
+	}
+	var key PublicKey
+	copy(key.ID[:], raw[2:10])
+	key.Key = ed25519.PublicKey(raw[10:])
+	return key, nil
+}
+
+// ParseSignature parses a .minisig or signify .sig file.
+func ParseSignature(data []byte) (Signature, error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "untrusted comment:") {
+		return Signature{}, errors.New("invalid signature file")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return Signature{}, errors.New("invalid signature encoding")
+	}
+	sig := Signature{Algorithm: string(raw[:2]), Signature: raw[10:]}
+	copy(sig.KeyID[:], raw[2:10])
+	if sig.Algorithm != algLegacy && sig.Algorithm != algPrehashed {
+		return Signature{}, fmt.Errorf("unsupported signature algorithm %q", sig.Algorithm)
+	}
+	if len(lines) >= 4 && lines[2] != "" {
+		comment, ok := strings.CutPrefix(lines[2], "trusted comment: ")
+		if !ok {
+			return Signature{}, errors.New("invalid trusted comment")
+		}
+		global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+		if err != nil || len(global) != ed25519.SignatureSize {
+			return Signature{}, errors.New("invalid global signature encoding")
+		}
+		sig.TrustedComment = comment
+		sig.GlobalSignature = global
+	} else if sig.Algorithm == algPrehashed {
+		return Signature{}, errors.New("prehashed signature without trusted comment")
+	}
+	return sig, nil
+}
+
+// Verify checks message against sig with the key matching its key ID.
+func Verify(message io.Reader, sig Signature, keys []PublicKey) error {
+	var key *PublicKey
+	for i := range keys {
+		if keys[i].ID == sig.KeyID {
+			key = &keys[i]
+			break
+		}
+	}
+	if key == nil {
+		return fmt.Errorf("signed by unknown key %s", keyIDString(sig.KeyID))
+	}
+	var signed []byte
+	if sig.Algorithm == algPrehashed {
+		h, err := blake2b.New512(nil)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(h, message); err != nil {
+			return err
+		}
+		signed = h.Sum(nil)
+	} else {
+		data, err := io.ReadAll(message)
+		if err != nil {
+			return err
+		}
+		signed = data
+	}
+	if !ed25519.Verify(key.Key, signed, sig.Signature) {
+		return fmt.Errorf("invalid signature by key %s", keyIDString(sig.KeyID))
+	}
+	if sig.GlobalSignature != nil {
+		global := append(append([]byte{}, sig.Signature...), sig.TrustedComment...)
+		if !ed25519.Verify(key.Key, global, sig.GlobalSignature) {
+			return fmt.Errorf("invalid trusted comment signature by key %s", keyIDString(sig.KeyID))
+		}
+	}
+	return nil
+}
+
+// keyIDString formats a key ID the way minisign prints it.
+func keyIDString(id [8]byte) string {
+	var rev [8]byte
+	for i := range id {
+		rev[i] = id[7-i]
+	}
+	return strings.ToUpper(hex.EncodeToString(rev[:]))
+}
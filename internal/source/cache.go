@@ -0,0 +1,163 @@
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ghpm/internal/httpclient"
+)
+
+// Cache keeps API responses on disk, grouped by source kind and repo. Entries
+// younger than TTL are used as is; older ones are revalidated with
+// If-None-Match/If-Modified-Since, so an unchanged listing costs a 304.
+type Cache struct {
+	Dir string
+	TTL time.Duration
+}
+
+type cacheEntry struct {
+	URL          string      `json:"url"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"lastModified,omitempty"`
+	Fetched      time.Time   `json:"fetched"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         []byte      `json:"body"`
+}
+
+func (c *Cache) path(scope, u string) string {
+	key := sha256.Sum256([]byte(u))
+	return filepath.Join(c.Dir, scope, hex.EncodeToString(key[:16])+".json")
+}
+
+func (c *Cache) load(scope, u string) (cacheEntry, bool) {
+	if c == nil {
+		return cacheEntry{}, false
+	}
+	data, err := os.ReadFile(c.path(scope, u))
+	if err != nil {
+		return cacheEntry{}, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != u {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *Cache) save(scope string, entry cacheEntry) error {
+	if c == nil {
+		return nil
+	}
+	// Listings of private repositories are fetched with a token, so they are
+	// only readable by the user running ghpm.
+	path := c.path(scope, entry.URL)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// cacheScope returns the cache subdirectory for a source kind and repo.
+func cacheScope(kind, repo string) string {
+	var b strings.Builder
+	for _, ch := range repo {
+		if (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch == '.' || ch == '-' {
+			b.WriteRune(ch)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return filepath.Join(kind, b.String())
+}
+
+// apiClient performs GET requests through the release metadata cache.
+type apiClient struct {
+	client *http.Client
+	cache  *Cache
+	scope  string
+}
+
+// get fetches u and returns the body and response headers, serving it from
+// the cache when fresh or when the server answers 304 Not Modified.
+func (a apiClient) get(u string, accept string, what string) ([]byte, http.Header, error) {
+	entry, cached := a.cache.load(a.scope, u)
+	if cached && a.cache.TTL > 0 && time.Since(entry.Fetched) < a.cache.TTL {
+		return entry.Body, entry.Header, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if cached {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+		entry.Fetched = time.Now()
+		_ = a.cache.save(a.scope, entry)
+		return entry.Body, entry.Header, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, nil, httpclient.StatusError(resp, what)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", what, err)
+	}
+	header := http.Header{}
+	for _, key := range []string{"Link", "X-Next-Page"} {
+		if values := resp.Header.Values(key); len(values) > 0 {
+			header[key] = values
+		}
+	}
+	entry = cacheEntry{
+		URL:          u,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Fetched:      time.Now(),
+		Header:       header,
+		Body:         body,
+	}
+	_ = a.cache.save(a.scope, entry)
+	return body, header, nil
+}
+
+// getJSON is get followed by decoding the body into v.
+func (a apiClient) getJSON(u string, accept string, what string, v any) (http.Header, error) {
+	body, header, err := a.get(u, accept, what)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, fmt.Errorf("%s: %w", what, err)
+	}
+	return header, nil
+}
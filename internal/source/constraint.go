@@ -0,0 +1,214 @@
+package source
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IsConstraint reports whether version is a range such as "~1.29",
+// ">=1.4 <2" or "1.29.x" rather than an exact tag.
+func IsConstraint(version string) bool {
+	version = strings.TrimSpace(version)
+	if version == "" {
+		return false
+	}
+	if strings.ContainsAny(version, "<>=~^!*, |") {
+		return true
+	}
+	m := partialRE.FindStringSubmatch(version)
+	if m == nil {
+		return false
+	}
+	for _, part := range m[1:4] {
+		if isWildcard(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// constraint is a disjunction of comparator sets: "a b || c" matches
+// versions satisfying both a and b, or c.
+type constraint struct {
+	text string
+	sets [][]comparator
+}
+
+type comparator struct {
+	op      string
+	version semver
+}
+
+var (
+	partialRE  = regexp.MustCompile(`^v?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?(?:-([0-9A-Za-z.-]+))?$`)
+	operatorRE = regexp.MustCompile(`(>=|<=|!=|>|<|=|~|\^)\s+`)
+)
+
+func parseConstraint(text string) (constraint, error) {
+	c := constraint{text: text}
+	normalized := operatorRE.ReplaceAllString(text, "$1")
+	for _, group := range strings.Split(normalized, "||") {
+		var set []comparator
+		for _, field := range strings.FieldsFunc(group, func(r rune) bool { return r == ' ' || r == ',' }) {
+			comps, err := parseComparator(field)
+			if err != nil {
+				return constraint{}, fmt.Errorf("invalid version constraint %q: %w", text, err)
+			}
+			set = append(set, comps...)
+		}
+		if len(set) == 0 {
+			return constraint{}, fmt.Errorf("invalid version constraint %q: empty range", text)
+		}
+		c.sets = append(c.sets, set)
+	}
+	return c, nil
+}
+
+// parseComparator expands one comparator into primitive >=, >, <, <=, =
+// and != bounds. Partial versions cover every version they prefix, so "<=1.4"
+// allows 1.4.9 and ">1.4" starts at 1.5.0.
+func parseComparator(field string) ([]comparator, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(field, candidate) {
+			op = candidate
+			break
+		}
+	}
+	lower, upper, parts, err := parsePartial(strings.TrimPrefix(field, op))
+	if err != nil {
+		return nil, err
+	}
+	exact := parts == 3
+	switch op {
+	case "", "=":
+		if exact {
+			return []comparator{{op: "=", version: lower}}, nil
+		}
+		return bounded(lower, upper, parts), nil
+	case "!=":
+		return []comparator{{op: "!=", version: lower}}, nil
+	case ">=":
+		return []comparator{{op: ">=", version: lower}}, nil
+	case "<":
+		return []comparator{{op: "<", version: lower}}, nil
+	case ">":
+		if exact {
+			return []comparator{{op: ">", version: lower}}, nil
+		}
+		return []comparator{{op: ">=", version: upper}}, nil
+	case "<=":
+		if exact {
+			return []comparator{{op: "<=", version: lower}}, nil
+		}
+		return []comparator{{op: "<", version: upper}}, nil
+	case "~":
+		if parts == 3 {
+			_, upper, _, _ = parsePartial(fmt.Sprintf("%d.%d", lower.nums[0], lower.nums[1]))
+		}
+		return bounded(lower, upper, parts), nil
+	case "^":
+		idx := 0
+		for idx < parts-1 && lower.nums[idx] == 0 {
+			idx++
+		}
+		upper = semver{}
+		copy(upper.nums[:idx], lower.nums[:idx])
+		upper.nums[idx] = lower.nums[idx] + 1
+		return bounded(lower, upper, parts), nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+func bounded(lower, upper semver, parts int) []comparator {
+	if parts == 0 {
+		return []comparator{{op: ">=", version: semver{}}}
+	}
+	return []comparator{{op: ">=", version: lower}, {op: "<", version: upper}}
+}
+
+// parsePartial parses a possibly partial version. It returns the lowest
+// version it covers, the first version above that range, and the number of
+// numeric components given.
+func parsePartial(s string) (semver, semver, int, error) {
+	m := partialRE.FindStringSubmatch(s)
+	if m == nil {
+		return semver{}, semver{}, 0, fmt.Errorf("invalid version %q", s)
+	}
+	var lower semver
+	parts := 0
+	for i := 0; i < 3; i++ {
+		if m[i+1] == "" || isWildcard(m[i+1]) {
+			break
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return semver{}, semver{}, 0, err
+		}
+		lower.nums[i] = n
+		parts++
+	}
+	if m[4] != "" && parts == 3 {
+		lower.pre = strings.Split(m[4], ".")
+	}
+	upper := lower
+	upper.pre = nil
+	if parts > 0 && parts < 3 {
+		upper.nums[parts-1]++
+		for i := parts; i < 3; i++ {
+			upper.nums[i] = 0
+		}
+	}
+	return lower, upper, parts, nil
+}
+
+func isWildcard(part string) bool {
+	return part == "x" || part == "X" || part == "*"
+}
+
+// matches reports whether tag parses as a semantic version inside the range.
+func (c constraint) matches(tag string) bool {
+	v, ok := parseSemver(tag)
+	if !ok {
+		return false
+	}
+	for _, set := range c.sets {
+		satisfied := true
+		for _, comp := range set {
+			if !comp.matches(v) {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			return true
+		}
+	}
+	return false
+}
+
+func (comp comparator) matches(v semver) bool {
+	cmp := v.compare(comp.version)
+	switch comp.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case "<":
+		// A release-only upper bound excludes prereleases of that release:
+		// "<2" must not admit 2.0.0-rc.1.
+		if len(comp.version.pre) == 0 && len(v.pre) > 0 && v.nums == comp.version.nums {
+			return false
+		}
+		return cmp < 0
+	}
+	return false
+}
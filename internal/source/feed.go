@@ -0,0 +1,118 @@
+package source
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The feed strategy resolves GitHub releases without REST API calls, which
+// keeps working on hosts that exhausted their anonymous API quota. The latest
+// release comes from the /releases/latest redirect and other lookups from the
+// releases Atom feed (which only lists recent releases). Asset lists are not
+// available, so assets are addressed by exact name under /releases/download.
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Updated time.Time  `xml:"updated"`
+	Links   []atomLink `xml:"link"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+func (r *githubResolver) resolveFromFeed(repo string, version string) (Release, error) {
+	if version != "" && !IsConstraint(version) {
+		return r.feedRelease(repo, version, time.Time{}), nil
+	}
+	if version == "" && r.policy.usesUpstreamLatest() {
+		tag, err := r.latestRedirect(repo)
+		if err != nil {
+			return Release{}, err
+		}
+		return r.feedRelease(repo, tag, time.Time{}), nil
+	}
+	releases, err := r.listFeed(repo)
+	if err != nil {
+		return Release{}, err
+	}
+	return r.policy.pick(repo, releases, version)
+}
+
+func (r *githubResolver) feedRelease(repo, tag string, published time.Time) Release {
+	return Release{
+		Tag:          tag,
+		Version:      r.policy.versionOf(tag),
+		Published:    published,
+		Prerelease:   isSemverPrerelease(tag),
+		DownloadBase: fmt.Sprintf("%s/%s/releases/download/%s", r.webURL, repo, url.PathEscape(tag)),
+	}
+}
+
+// latestRedirect reads the tag of the latest release from the Location of
+// the /releases/latest redirect.
+func (r *githubResolver) latestRedirect(repo string) (string, error) {
+	client := *r.api.client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	u := fmt.Sprintf("%s/%s/releases/latest", r.webURL, repo)
+	resp, err := client.Get(u)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return "", fmt.Errorf("github latest release: unexpected %s", resp.Status)
+	}
+	tag := tagFromReleaseURL(resp.Header.Get("Location"))
+	if tag == "" {
+		return "", fmt.Errorf("no releases found for %s", repo)
+	}
+	return tag, nil
+}
+
+func (r *githubResolver) listFeed(repo string) ([]Release, error) {
+	u := fmt.Sprintf("%s/%s/releases.atom", r.webURL, repo)
+	body, _, err := r.api.get(u, "application/atom+xml", "github releases feed")
+	if err != nil {
+		return nil, err
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("github releases feed: %w", err)
+	}
+	var releases []Release
+	for _, entry := range feed.Entries {
+		for _, link := range entry.Links {
+			if tag := tagFromReleaseURL(link.Href); tag != "" {
+				releases = append(releases, r.feedRelease(repo, tag, entry.Updated))
+				break
+			}
+		}
+	}
+	return releases, nil
+}
+
+// tagFromReleaseURL extracts the tag from a .../releases/tag/<tag> URL.
+func tagFromReleaseURL(u string) string {
+	_, tag, ok := strings.Cut(u, "/releases/tag/")
+	if !ok {
+		return ""
+	}
+	unescaped, err := url.PathUnescape(tag)
+	if err != nil {
+		return tag
+	}
+	return unescaped
+}
+
+var errNoAssetList = errors.New("asset patterns need the release asset list, which the feed strategy does not provide; use name")
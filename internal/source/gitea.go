@@ -0,0 +1,81 @@
+package source
+
+import (
+	"fmt"
+	"time"
+)
+
+type giteaResolver struct {
+	api    apiClient
+	policy policy
+	kind   string
+	apiURL string
+}
+
+type giteaRelease struct {
+	TagName    string       `json:"tag_name"`
+	ID         int64        `json:"id"`
+	Draft      bool         `json:"draft"`
+	Prerelease bool         `json:"prerelease"`
+	Published  time.Time    `json:"published_at"`
+	Assets     []giteaAsset `json:"assets"`
+}
+
+type giteaAsset struct {
+	Name    string    `json:"name"`
+	URL     string    `json:"browser_download_url"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created_at"`
+}
+
+func (r *giteaResolver) ResolveRelease(repo string, version string) (Release, error) {
+	releases, err := r.listReleases(repo, version)
+	if err != nil {
+		return Release{}, err
+	}
+	return r.policy.pick(repo, releases, version)
+}
+
+// listReleases walks the paginated release listing, skipping drafts. When
+// version is set, it stops at the first page that contains that tag.
+func (r *giteaResolver) listReleases(repo string, version string) ([]Release, error) {
+	u := fmt.Sprintf("%s/repos/%s/releases?limit=50", r.apiURL, repo)
+	var all []Release
+	for u != "" {
+		var releases []giteaRelease
+		header, err := r.api.getJSON(u, "application/json", r.kind+" releases", &releases)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range releases {
+			if rel.Draft {
+				continue
+			}
+			all = append(all, mapGiteaRelease(rel))
+		}
+		if version != "" && containsTag(all, version) {
+			break
+		}
+		u = nextPageURL(header)
+	}
+	return all, nil
+}
+
+func mapGiteaRelease(rel giteaRelease) Release {
+	assets := make([]Asset, 0, len(rel.Assets))
+	for _, a := range rel.Assets {
+		assets = append(assets, Asset{
+			Name:    a.Name,
+			URL:     a.URL,
+			Size:    a.Size,
+			Updated: a.Created,
+		})
+	}
+	return Release{
+		Tag:        rel.TagName,
+		ID:         rel.ID,
+		Published:  rel.Published,
+		Prerelease: rel.Prerelease,
+		Assets:     assets,
+	}
+}
@@ -0,0 +1,192 @@
+package source
+
+import (
+	"fmt"
+	"time"
+)
+
+type githubResolver struct {
+	api    apiClient
+	policy policy
+	apiURL string
+	webURL string
+	from   string
+	latest string
+}
+
+type githubRelease struct {
+	TagName    string        `json:"tag_name"`
+	ID         int64         `json:"id"`
+	Draft      bool          `json:"draft"`
+	Prerelease bool          `json:"prerelease"`
+	Published  time.Time     `json:"published_at"`
+	Assets     []githubAsset `json:"assets"`
+}
+
+type githubAsset struct {
+	Name    string    `json:"name"`
+	URL     string    `json:"browser_download_url"`
+	APIURL  string    `json:"url"`
+	Size    int64     `json:"size"`
+	Updated time.Time `json:"updated_at"`
+	Digest  string    `json:"digest"`
+}
+
+type githubTag struct {
+	Name       string `json:"name"`
+	TarballURL string `json:"tarball_url"`
+	ZipballURL string `json:"zipball_url"`
+	Commit     struct {
+		SHA string `json:"sha"`
+		URL string `json:"url"`
+	} `json:"commit"`
+}
+
+type githubCommit struct {
+	Commit struct {
+		Committer struct {
+			Date time.Time `json:"date"`
+		} `json:"committer"`
+	} `json:"commit"`
+}
+
+func (r *githubResolver) ResolveRelease(repo string, version string) (Release, error) {
+	if r.from == "feed" {
+		return r.resolveFromFeed(repo, version)
+	}
+	if r.from != "tags" && version == "" && r.latest == "upstream" && r.policy.usesUpstreamLatest() {
+		return r.resolveUpstreamLatest(repo)
+	}
+	var releases []Release
+	var err error
+	if r.from == "tags" {
+		releases, err = r.listTags(repo, version)
+	} else {
+		releases, err = r.listReleases(repo, version)
+	}
+	if err != nil {
+		return Release{}, err
+	}
+	return r.policy.pick(repo, releases, version)
+}
+
+// resolveUpstreamLatest returns the release the maintainers marked as latest,
+// which may differ from the highest version when older lines get backports.
+func (r *githubResolver) resolveUpstreamLatest(repo string) (Release, error) {
+	releases, err := r.listReleases(repo, "")
+	if err != nil {
+		return Release{}, err
+	}
+	ordered, err := r.policy.pick(repo, releases, "")
+	if err != nil {
+		return Release{}, err
+	}
+	var latest githubRelease
+	u := fmt.Sprintf("%s/repos/%s/releases/latest", r.apiURL, repo)
+	if _, err := r.api.getJSON(u, "application/vnd.github+json", "github latest release", &latest); err != nil {
+		return Release{}, err
+	}
+	rel := mapGitHubRelease(latest)
+	rel.Version = r.policy.versionOf(rel.Tag)
+	if rel.Tag != ordered.Tag {
+		rel.OrderedTag = ordered.Tag
+	}
+	return rel, nil
+}
+
+// listReleases walks the paginated release listing, skipping drafts. When
+// version is set, it stops at the first page that contains that tag.
+func (r *githubResolver) listReleases(repo string, version string) ([]Release, error) {
+	u := fmt.Sprintf("%s/repos/%s/releases?per_page=100", r.apiURL, repo)
+	var all []Release
+	for u != "" {
+		var releases []githubRelease
+		header, err := r.api.getJSON(u, "application/vnd.github+json", "github releases", &releases)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range releases {
+			if rel.Draft {
+				continue
+			}
+			all = append(all, mapGitHubRelease(rel))
+		}
+		if version != "" && containsTag(all, version) {
+			break
+		}
+		u = nextPageURL(header)
+	}
+	return all, nil
+}
+
+func mapGitHubRelease(rel githubRelease) Release {
+	assets := make([]Asset, 0, len(rel.Assets))
+	for _, a := range rel.Assets {
+		assets = append(assets, Asset{
+			Name:    a.Name,
+			URL:     a.URL,
+			APIURL:  a.APIURL,
+			Size:    a.Size,
+			Updated: a.Updated,
+			Digest:  a.Digest,
+		})
+	}
+	return Release{
+		Tag:        rel.TagName,
+		ID:         rel.ID,
+		Published:  rel.Published,
+		Prerelease: rel.Prerelease,
+		Assets:     assets,
+	}
+}
+
+// maxTagDateLookups caps the commit requests listTags makes for dates, one
+// per tag. Tags past it, in listing order, are ordered by name.
+const maxTagDateLookups = 20
+
+// listTags lists git tags as releases whose only assets are the source
+// archives. The tags API carries no dates, so commit dates are fetched only
+// for the first maxTagDateLookups tags the version scheme cannot order.
+func (r *githubResolver) listTags(repo string, version string) ([]Release, error) {
+	u := fmt.Sprintf("%s/repos/%s/tags?per_page=100", r.apiURL, repo)
+	var all []Release
+	var commitURLs []string
+	for u != "" {
+		var tags []githubTag
+		header, err := r.api.getJSON(u, "application/vnd.github+json", "github tags", &tags)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			all = append(all, Release{
+				Tag:        tag.Name,
+				Prerelease: isSemverPrerelease(tag.Name),
+				Assets:     sourceArchiveAssets(tag.TarballURL, tag.ZipballURL),
+			})
+			commitURLs = append(commitURLs, tag.Commit.URL)
+		}
+		if version != "" && containsTag(all, version) {
+			break
+		}
+		u = nextPageURL(header)
+	}
+	if version != "" && !IsConstraint(version) {
+		return all, nil
+	}
+	lookups := 0
+	for i := range all {
+		if r.policy.orderable(all[i].Tag) || commitURLs[i] == "" {
+			continue
+		}
+		if lookups == maxTagDateLookups {
+			break
+		}
+		lookups++
+		var commit githubCommit
+		if _, err := r.api.getJSON(commitURLs[i], "application/vnd.github+json", "github commit", &commit); err != nil {
+			return nil, err
+		}
+		all[i].Published = commit.Commit.Committer.Date
+	}
+	return all, nil
+}
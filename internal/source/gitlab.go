@@ -0,0 +1,133 @@
+package source
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+type gitlabResolver struct {
+	api    apiClient
+	policy policy
+	apiURL string
+	from   string
+}
+
+type gitlabRelease struct {
+	TagName  string `json:"tag_name"`
+	Released string `json:"released_at"`
+	Upcoming bool   `json:"upcoming_release"`
+	Assets   struct {
+		Links []gitlabAsset `json:"links"`
+	} `json:"assets"`
+}
+
+type gitlabAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type gitlabTag struct {
+	Name   string `json:"name"`
+	Commit struct {
+		CommittedDate string `json:"committed_date"`
+	} `json:"commit"`
+}
+
+func (r *gitlabResolver) ResolveRelease(repo string, version string) (Release, error) {
+	var releases []Release
+	var err error
+	if r.from == "tags" {
+		releases, err = r.listTags(repo, version)
+	} else {
+		releases, err = r.listReleases(repo, version)
+	}
+	if err != nil {
+		return Release{}, err
+	}
+	return r.policy.pick(repo, releases, version)
+}
+
+// listReleases walks the paginated release listing using GitLab's
+// X-Next-Page header. When version is set, it stops at the first page that
+// contains that tag.
+func (r *gitlabResolver) listReleases(repo string, version string) ([]Release, error) {
+	project := url.PathEscape(repo)
+	base := fmt.Sprintf("%s/projects/%s/releases?per_page=100", r.apiURL, project)
+	var all []Release
+	page := "1"
+	for page != "" {
+		var releases []gitlabRelease
+		header, err := r.api.getJSON(base+"&page="+url.QueryEscape(page), "", "gitlab releases", &releases)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range releases {
+			all = append(all, mapGitLabRelease(rel))
+		}
+		if version != "" && containsTag(all, version) {
+			break
+		}
+		page = header.Get("X-Next-Page")
+	}
+	return all, nil
+}
+
+// listTags lists git tags as releases whose only assets are the source
+// archives, dated by their commit.
+func (r *gitlabResolver) listTags(repo string, version string) ([]Release, error) {
+	project := url.PathEscape(repo)
+	base := fmt.Sprintf("%s/projects/%s/repository/tags?per_page=100", r.apiURL, project)
+	var all []Release
+	page := "1"
+	for page != "" {
+		var tags []gitlabTag
+		header, err := r.api.getJSON(base+"&page="+url.QueryEscape(page), "", "gitlab tags", &tags)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			archive := fmt.Sprintf("%s/projects/%s/repository/archive", r.apiURL, project)
+			sha := "?sha=" + url.QueryEscape(tag.Name)
+			all = append(all, Release{
+				Tag:        tag.Name,
+				Published:  parseGitLabTime(tag.Commit.CommittedDate),
+				Prerelease: isSemverPrerelease(tag.Name),
+				Assets:     sourceArchiveAssets(archive+".tar.gz"+sha, archive+".zip"+sha),
+			})
+		}
+		if version != "" && containsTag(all, version) {
+			break
+		}
+		page = header.Get("X-Next-Page")
+	}
+	return all, nil
+}
+
+// mapGitLabRelease converts a GitLab release. Upcoming releases (with a
+// release date in the future) are treated as prereleases.
+func mapGitLabRelease(rel gitlabRelease) Release {
+	assets := make([]Asset, 0, len(rel.Assets.Links))
+	for _, a := range rel.Assets.Links {
+		assets = append(assets, Asset{
+			Name: a.Name,
+			URL:  a.URL,
+		})
+	}
+	return Release{
+		Tag:        rel.TagName,
+		Published:  parseGitLabTime(rel.Released),
+		Prerelease: rel.Upcoming,
+		Assets:     assets,
+	}
+}
+
+func parseGitLabTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,166 @@
+package source
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ghpm/internal/manifest"
+)
+
+// httpResolver serves direct-download sources. Without discovery it only
+// accepts an explicit version; with discovery it reads the available
+// versions from a JSON endpoint, an HTML index or a plain-text file.
+type httpResolver struct {
+	api       apiClient
+	policy    policy
+	discovery *manifest.Discovery
+}
+
+func (r *httpResolver) ResolveRelease(repo string, version string) (Release, error) {
+	if version != "" && !IsConstraint(version) {
+		return Release{Tag: version, Version: version}, nil
+	}
+	if r.discovery == nil {
+		if version == "" {
+			return Release{}, errors.New("http source requires explicit --version")
+		}
+		return Release{}, fmt.Errorf("http source cannot resolve version constraint %q without discovery", version)
+	}
+	versions, err := r.discover()
+	if err != nil {
+		return Release{}, err
+	}
+	releases := make([]Release, 0, len(versions))
+	for _, v := range versions {
+		releases = append(releases, Release{Tag: v, Prerelease: isSemverPrerelease(v)})
+	}
+	name := repo
+	if name == "" {
+		name = r.discovery.URL
+	}
+	return r.policy.pick(name, releases, version)
+}
+
+func (r *httpResolver) discover() ([]string, error) {
+	d := r.discovery
+	body, _, err := r.api.get(d.URL, "", "discovery "+d.URL)
+	if err != nil {
+		return nil, err
+	}
+	var re *regexp.Regexp
+	if d.Pattern != "" {
+		re, err = regexp.Compile(d.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("source.discovery.pattern: %w", err)
+		}
+	}
+	var candidates []string
+	switch d.Type {
+	case "json":
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, fmt.Errorf("discovery %s: %w", d.URL, err)
+		}
+		candidates, err = evalJSONPath(doc, d.Path)
+		if err != nil {
+			return nil, fmt.Errorf("source.discovery.path: %w", err)
+		}
+	case "html":
+		if re == nil {
+			return nil, errors.New("source.discovery.pattern is required for html discovery")
+		}
+		candidates = []string{string(body)}
+	case "text":
+		for _, line := range strings.Split(string(body), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				candidates = append(candidates, line)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("source.discovery.type %q is unsupported", d.Type)
+	}
+	seen := map[string]bool{}
+	var versions []string
+	add := func(v string) {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			versions = append(versions, v)
+		}
+	}
+	for _, c := range candidates {
+		if re == nil {
+			add(c)
+			continue
+		}
+		for _, m := range re.FindAllStringSubmatch(c, -1) {
+			add(patternValue(re, m))
+		}
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("discovery %s: no versions found", d.URL)
+	}
+	return versions, nil
+}
+
+// patternValue returns the "version" named group of a match, else its first
+// group, else the whole match.
+func patternValue(re *regexp.Regexp, m []string) string {
+	if idx := re.SubexpIndex("version"); idx > 0 {
+		return m[idx]
+	}
+	if len(m) > 1 {
+		return m[1]
+	}
+	return m[0]
+}
+
+// evalJSONPath evaluates a dotted path such as "versions.*.version" or
+// "$.releases[*].tag" and returns the scalar values it reaches. "*" walks
+// every element of an array or every value of an object.
+func evalJSONPath(doc any, path string) ([]string, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	nodes := []any{doc}
+	for _, seg := range strings.Split(path, ".") {
+		if seg == "" {
+			continue
+		}
+		var next []any
+		for _, node := range nodes {
+			switch v := node.(type) {
+			case map[string]any:
+				if seg == "*" {
+					for _, child := range v {
+						next = append(next, child)
+					}
+				} else if child, ok := v[seg]; ok {
+					next = append(next, child)
+				}
+			case []any:
+				if seg == "*" {
+					next = append(next, v...)
+				} else if i, err := strconv.Atoi(seg); err == nil && i >= 0 && i < len(v) {
+					next = append(next, v[i])
+				}
+			}
+		}
+		nodes = next
+	}
+	var values []string
+	for _, node := range nodes {
+		switch v := node.(type) {
+		case string:
+			values = append(values, v)
+		case float64:
+			values = append(values, strconv.FormatFloat(v, 'f', -1, 64))
+		case nil:
+		default:
+			return nil, fmt.Errorf("path %q reaches a non-scalar value", path)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,262 @@
+package source
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+
+	"ghpm/internal/manifest"
+)
+
+// Platform is the system assets are selected for.
+type Platform struct {
+	OS   string
+	Arch string
+	// Libc is "gnu" or "musl" on Linux, empty elsewhere.
+	Libc string
+	// OSMap and ArchMap map GOOS/GOARCH values to the tokens a project uses
+	// in its asset names (manifest osMap/archMap).
+	OSMap   map[string]string
+	ArchMap map[string]string
+}
+
+var osAliases = map[string][]string{
+	"linux":   {"linux"},
+	"darwin":  {"darwin", "macos", "mac", "osx", "apple"},
+	"windows": {"windows", "win", "win32", "win64"},
+	"freebsd": {"freebsd"},
+	"openbsd": {"openbsd"},
+	"netbsd":  {"netbsd"},
+}
+
+// Asset names are normalized before matching so that x86_64 does not also
+// read as the 386 alias x86.
+var archAliases = map[string][]string{
+	"amd64":   {"amd64", "x64", "64bit"},
+	"arm64":   {"arm64", "aarch64", "armv8"},
+	"386":     {"386", "i386", "i686", "x86", "32bit"},
+	"arm":     {"arm", "armv6", "armv6l", "armv7", "armv7l", "armhf", "armel"},
+	"riscv64": {"riscv64"},
+	"ppc64le": {"ppc64le"},
+	"s390x":   {"s390x"},
+	"loong64": {"loong64", "loongarch64"},
+}
+
+var (
+	archiveSuffixes = []string{".tar.gz", ".tgz", ".tar.xz", ".txz", ".zip"}
+	packageSuffixes = []string{".deb", ".rpm", ".apk", ".pkg", ".msi", ".dmg", ".appimage", ".snap", ".flatpak"}
+	ignoredSuffixes = []string{".sha1", ".sha256", ".sha256sum", ".sha512", ".sha512sum", ".md5", ".sig", ".asc", ".minisig", ".pem", ".crt", ".cert", ".pub", ".sbom", ".spdx", ".json", ".jsonl", ".txt", ".sum"}
+	ignoredNames    = []string{"checksums", "sha256sums", "sha512sums"}
+
+	// unsupportedArchiveSuffixes are archives extract cannot unpack (see
+	// inferArchiveFormat), so they rank last along with packages.
+	unsupportedArchiveSuffixes = []string{".tar.bz2", ".tbz", ".tar.zst", ".7z"}
+)
+
+func (p Platform) String() string {
+	return p.OS + "/" + p.Arch
+}
+
+// Native reports whether the platform is the one ghpm runs on.
+func (p Platform) Native() bool {
+	return p.OS == runtime.GOOS && p.Arch == runtime.GOARCH
+}
+
+func (p Platform) osTokens() []string {
+	return withMapped(osAliases[p.OS], p.OSMap[p.OS])
+}
+
+func (p Platform) archTokens() []string {
+	return withMapped(archAliases[p.Arch], p.ArchMap[p.Arch])
+}
+
+func withMapped(aliases []string, mapped string) []string {
+	if mapped == "" {
+		return aliases
+	}
+	return append([]string{normalizeAssetName(mapped)}, aliases...)
+}
+
+func normalizeAssetName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.ReplaceAll(name, "x86_64", "amd64")
+	return strings.ReplaceAll(name, "x86-64", "amd64")
+}
+
+// hasToken reports whether name contains token delimited by non-alphanumeric
+// characters, so that "arm" does not match "arm64".
+func hasToken(name, token string) bool {
+	for start := 0; start <= len(name); {
+		i := strings.Index(name[start:], token)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(token)
+		if (i == 0 || !isAlnum(name[i-1])) && (end == len(name) || !isAlnum(name[end])) {
+			return true
+		}
+		start = i + 1
+	}
+	return false
+}
+
+func isAlnum(c byte) bool {
+	return 'a' <= c && c <= 'z' || '0' <= c && c <= '9'
+}
+
+func hasAnyToken(name string, tokens []string) bool {
+	for _, token := range tokens {
+		if hasToken(name, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// mentionsOther reports whether name carries a token of another entry of
+// aliases than the target, ignoring tokens the target shares.
+func mentionsOther(name string, aliases map[string][]string, target string, own []string) bool {
+	for key, tokens := range aliases {
+		if key == target {
+			continue
+		}
+		for _, token := range tokens {
+			if !contains(own, token) && hasToken(name, token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnySuffix(name string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMetadataAsset reports whether an asset is a checksum, signature or SBOM
+// file rather than something installable.
+func isMetadataAsset(name string) bool {
+	name = strings.ToLower(name)
+	if hasAnySuffix(name, ignoredSuffixes) {
+		return true
+	}
+	for _, ignored := range ignoredNames {
+		if strings.Contains(name, ignored) {
+			return true
+		}
+	}
+	return false
+}
+
+// scoreAsset rates how well an asset fits the platform; ok is false when it
+// is built for another platform or is not installable. archive favours
+// archives over bare files (extract actions), and the reverse otherwise.
+func scoreAsset(name string, platform Platform, archive bool) (int, bool) {
+	if isMetadataAsset(name) {
+		return 0, false
+	}
+	n := normalizeAssetName(name)
+	score := 0
+
+	osTokens := platform.osTokens()
+	switch {
+	case hasAnyToken(n, osTokens):
+		score += 8
+	case mentionsOther(n, osAliases, platform.OS, osTokens):
+		return 0, false
+	case platform.OS != "windows" && strings.HasSuffix(n, ".exe"):
+		return 0, false
+	}
+
+	archTokens := platform.archTokens()
+	switch {
+	case hasAnyToken(n, archTokens):
+		score += 8
+	case platform.OS == "darwin" && hasAnyToken(n, []string{"universal", "all"}):
+		score += 4
+	case mentionsOther(n, archAliases, platform.Arch, archTokens):
+		return 0, false
+	}
+
+	if platform.OS == "linux" {
+		musl := hasToken(n, "musl")
+		gnu := hasToken(n, "gnu") || hasToken(n, "glibc")
+		switch {
+		case platform.Libc == "musl" && musl, platform.Libc != "musl" && gnu:
+			score += 2
+		case platform.Libc == "musl" && gnu:
+			return 0, false
+		case musl:
+			// Static musl builds run on glibc systems too.
+			score++
+		}
+	}
+
+	switch {
+	case hasAnySuffix(n, archiveSuffixes):
+		if archive {
+			score += 4
+		}
+		if strings.HasSuffix(n, ".zip") == (platform.OS == "windows") {
+			score++
+		}
+	case hasAnySuffix(n, packageSuffixes), hasAnySuffix(n, unsupportedArchiveSuffixes):
+		score -= 4
+	default:
+		if !archive {
+			score += 4
+		}
+	}
+	return score, true
+}
+
+type scoredAsset struct {
+	asset Asset
+	score int
+}
+
+// selectForPlatform returns the candidates scoring best for the platform;
+// several are returned on a tie for first place.
+func selectForPlatform(candidates []Asset, platform Platform, archive bool) ([]Asset, error) {
+	var scored []scoredAsset
+	for _, asset := range candidates {
+		if score, ok := scoreAsset(asset.Name, platform, archive); ok {
+			scored = append(scored, scoredAsset{asset: asset, score: score})
+		}
+	}
+	if len(scored) == 0 {
+		return nil, fmt.Errorf("no asset found for %s", platform)
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+	var best []Asset
+	for _, s := range scored {
+		if s.score == scored[0].score {
+			best = append(best, s.asset)
+		}
+	}
+	return best, nil
+}
+
+// SelectArchive is SelectAsset for extract actions: automatic selection
+// favours archives over bare files.
+func SelectArchive(release Release, action manifest.AssetAction, platform Platform) (Asset, error) {
+	return selectAsset(release, action, platform, true)
+}
@@ -0,0 +1,165 @@
+package source
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"ghpm/internal/manifest"
+)
+
+// policy decides which releases are candidates for "latest" and picks one.
+type policy struct {
+	channel   string
+	channelRE *regexp.Regexp
+	scheme    versionScheme
+	tagRE     *regexp.Regexp
+	versionRE *regexp.Regexp
+}
+
+func newPolicy(src manifest.Source) (policy, error) {
+	scheme, err := newVersionScheme(src.VersionScheme)
+	if err != nil {
+		return policy{}, err
+	}
+	p := policy{channel: src.Channel, scheme: scheme}
+	switch src.Channel {
+	case "", "stable", "prerelease":
+	default:
+		re, err := regexp.Compile(src.Channel)
+		if err != nil {
+			return policy{}, fmt.Errorf("source.channel: %w", err)
+		}
+		p.channelRE = re
+	}
+	if src.TagPattern != "" {
+		re, err := regexp.Compile(src.TagPattern)
+		if err != nil {
+			return policy{}, fmt.Errorf("source.tagPattern: %w", err)
+		}
+		p.tagRE = re
+	}
+	if src.VersionPattern != "" {
+		re, err := regexp.Compile(src.VersionPattern)
+		if err != nil {
+			return policy{}, fmt.Errorf("source.versionPattern: %w", err)
+		}
+		if re.NumSubexp() == 0 {
+			return policy{}, fmt.Errorf("source.versionPattern: regex %q has no capture group", src.VersionPattern)
+		}
+		p.versionRE = re
+	}
+	return p, nil
+}
+
+// versionOf derives the version of a tag from versionPattern: the "version"
+// named group if present, the first group otherwise. Tags that do not match,
+// or sources without a pattern, use the tag itself.
+func (p policy) versionOf(tag string) string {
+	if p.versionRE == nil {
+		return tag
+	}
+	m := p.versionRE.FindStringSubmatch(tag)
+	if m == nil {
+		return tag
+	}
+	if idx := p.versionRE.SubexpIndex("version"); idx > 0 {
+		return m[idx]
+	}
+	return m[1]
+}
+
+// allows reports whether a release matches tagPattern and belongs to the
+// channel: "stable" (the default) excludes prereleases, "prerelease" accepts
+// everything, and any other value is a regular expression the tag must match.
+func (p policy) allows(rel Release) bool {
+	if p.tagRE != nil && !p.tagRE.MatchString(rel.Tag) {
+		return false
+	}
+	switch {
+	case p.channelRE != nil:
+		return p.channelRE.MatchString(rel.Tag)
+	case p.channel == "prerelease":
+		return true
+	default:
+		return !rel.Prerelease
+	}
+}
+
+// pick returns the release tagged version (or whose derived version is
+// version), or the highest release of the channel when version is empty or a
+// constraint. An exact version bypasses the channel.
+func (p policy) pick(repo string, releases []Release, version string) (Release, error) {
+	if len(releases) == 0 {
+		return Release{}, fmt.Errorf("no releases found for %s", repo)
+	}
+	for i := range releases {
+		releases[i].Version = p.versionOf(releases[i].Tag)
+	}
+	if version != "" && !IsConstraint(version) {
+		for _, rel := range releases {
+			if rel.Tag == version {
+				return rel, nil
+			}
+		}
+		for _, rel := range releases {
+			if rel.Version == version && (p.tagRE == nil || p.tagRE.MatchString(rel.Tag)) {
+				return rel, nil
+			}
+		}
+		return Release{}, fmt.Errorf("version %s not found", version)
+	}
+	var con *constraint
+	if version != "" {
+		parsed, err := parseConstraint(version)
+		if err != nil {
+			return Release{}, err
+		}
+		con = &parsed
+	}
+	var candidates []Release
+	for _, rel := range releases {
+		if p.allows(rel) && (con == nil || con.matches(rel.Version)) {
+			candidates = append(candidates, rel)
+		}
+	}
+	if len(candidates) == 0 {
+		if con != nil {
+			return Release{}, fmt.Errorf("no releases of %s in channel %s satisfy %s", repo, p.name(), con.text)
+		}
+		return Release{}, fmt.Errorf("no releases found for %s in channel %s", repo, p.name())
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return compareReleases(p.scheme, candidates[i].Version, candidates[j].Version, candidates[i].Published, candidates[j].Published) > 0
+	})
+	return candidates[0], nil
+}
+
+// usesUpstreamLatest reports whether the upstream "latest" release is an
+// acceptable answer: the stable channel without tag filtering.
+func (p policy) usesUpstreamLatest() bool {
+	return p.tagRE == nil && p.channelRE == nil && p.channel != "prerelease"
+}
+
+// orderable reports whether the version scheme can order tag without
+// falling back to publish dates.
+func (p policy) orderable(tag string) bool {
+	_, ok := p.scheme.compare(p.versionOf(tag), p.versionOf(tag))
+	return ok
+}
+
+func (p policy) name() string {
+	if p.channel == "" {
+		return "stable"
+	}
+	return p.channel
+}
+
+func containsTag(releases []Release, tag string) bool {
+	for _, rel := range releases {
+		if rel.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
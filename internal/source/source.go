@@ -1,13 +1,11 @@
 package source
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"path"
-	"sort"
 	"strings"
 	"time"
 
@@ -15,284 +13,257 @@ import (
 )
 
 type Release struct {
-	Tag       string
-	ID        int64
-	Published time.Time
-	Assets    []Asset
+	Tag string
+	// Version is the version derived from Tag by source.versionPattern; it
+	// equals Tag when the source has no pattern.
+	Version    string
+	ID         int64
+	Published  time.Time
+	Prerelease bool
+	Assets     []Asset
+	// DownloadBase is set when the asset list is unknown (feed strategy);
+	// assets are then addressed as DownloadBase/<name>.
+	DownloadBase string
+	// OrderedTag is the tag versionScheme ranks highest when source.latest
+	// defers to upstream and the two disagree.
+	OrderedTag string
 }
 
 type Asset struct {
 	Name string
 	URL  string
-	Size int64
+	// APIURL is the API endpoint serving the asset content when requested
+	// with Accept: application/octet-stream (GitHub only). Unlike URL, it
+	// works for private repositories.
+	APIURL  string
+	Size    int64
+	Updated time.Time
+	// Digest is the "algo:hex" digest the forge recorded at upload (GitHub).
+	Digest string
 }
 
 type Resolver interface {
 	ResolveRelease(repo string, version string) (Release, error)
 }
 
-func NewResolver(kind string, client *http.Client) (Resolver, error) {
-	switch kind {
+// NewResolver returns the resolver for a source. cache may be nil to disable
+// the on-disk release metadata cache.
+func NewResolver(src manifest.Source, client *http.Client, cache *Cache) (Resolver, error) {
+	api := apiClient{client: client, cache: cache, scope: cacheScope(src.Kind, src.Repo)}
+	pol, err := newPolicy(src)
+	if err != nil {
+		return nil, err
+	}
+	switch src.Kind {
 	case "github":
-		return &githubResolver{client: client}, nil
+		return &githubResolver{api: api, policy: pol, apiURL: apiBaseURL(src), webURL: webBaseURL(src), from: src.From, latest: src.Latest}, nil
 	case "gitlab":
-		return &gitlabResolver{client: client}, nil
+		return &gitlabResolver{api: api, policy: pol, apiURL: apiBaseURL(src), from: src.From}, nil
+	case "gitea", "forgejo":
+		return &giteaResolver{api: api, policy: pol, kind: src.Kind, apiURL: apiBaseURL(src)}, nil
 	case "http":
-		return &httpResolver{}, nil
+		if src.Discovery != nil && src.Repo == "" {
+			api.scope = cacheScope(src.Kind, src.Discovery.URL)
+		}
+		return &httpResolver{api: api, policy: pol, discovery: src.Discovery}, nil
 	default:
-		return nil, fmt.Errorf("unknown source kind %q", kind)
+		return nil, fmt.Errorf("unknown source kind %q", src.Kind)
 	}
 }
 
-type httpResolver struct{}
-
-func (r *httpResolver) ResolveRelease(repo string, version string) (Release, error) {
-	if version == "" {
-		return Release{}, errors.New("http source requires explicit --version")
-	}
-	return Release{Tag: version}, nil
-}
-
-type githubResolver struct {
-	client *http.Client
-}
-
-type githubRelease struct {
-	TagName    string        `json:"tag_name"`
-	ID         int64         `json:"id"`
-	Draft      bool          `json:"draft"`
-	Prerelease bool          `json:"prerelease"`
-	Published  time.Time     `json:"published_at"`
-	Assets     []githubAsset `json:"assets"`
-}
-
-type githubAsset struct {
-	Name string `json:"name"`
-	URL  string `json:"browser_download_url"`
-	Size int64  `json:"size"`
+var defaultHosts = map[string]string{
+	"github":  "github.com",
+	"gitlab":  "gitlab.com",
+	"gitea":   "gitea.com",
+	"forgejo": "codeberg.org",
 }
 
-func (r *githubResolver) ResolveRelease(repo string, version string) (Release, error) {
-	releases, err := r.listReleases(repo)
-	if err != nil {
-		return Release{}, err
+// apiBaseURL returns the REST API root for a source. An explicit apiURL wins;
+// otherwise it is derived from host using each forge's standard API prefix.
+func apiBaseURL(src manifest.Source) string {
+	if src.APIURL != "" {
+		return strings.TrimSuffix(src.APIURL, "/")
 	}
-	if len(releases) == 0 {
-		return Release{}, fmt.Errorf("no releases found for %s", repo)
+	host := src.Host
+	if host == "" {
+		host = defaultHosts[src.Kind]
 	}
-	if version != "" {
-		for _, rel := range releases {
-			if rel.TagName == version {
-				return mapGitHubRelease(rel), nil
-			}
+	switch src.Kind {
+	case "github":
+		if host == "github.com" {
+			return "https://api.github.com"
 		}
-		return Release{}, fmt.Errorf("version %s not found", version)
+		return hostBaseURL(host) + "/api/v3"
+	case "gitlab":
+		return hostBaseURL(host) + "/api/v4"
+	default:
+		return hostBaseURL(host) + "/api/v1"
 	}
-	sort.Slice(releases, func(i, j int) bool {
-		return compareReleases(releases[i].TagName, releases[j].TagName, releases[i].Published, releases[j].Published) > 0
-	})
-	return mapGitHubRelease(releases[0]), nil
 }
 
-func (r *githubResolver) listReleases(repo string) ([]githubRelease, error) {
-	u := fmt.Sprintf("https://api.github.com/repos/%s/releases", repo)
-	req, err := http.NewRequest(http.MethodGet, u, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/vnd.github+json")
-	resp, err := r.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("github releases: %s", resp.Status)
-	}
-	var releases []githubRelease
-	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
-		return nil, err
+// webBaseURL returns the web root of a source's host.
+func webBaseURL(src manifest.Source) string {
+	host := src.Host
+	if host == "" {
+		host = defaultHosts[src.Kind]
 	}
-	filtered := make([]githubRelease, 0, len(releases))
-	for _, r := range releases {
-		if r.Draft || r.Prerelease {
-			continue
-		}
-		filtered = append(filtered, r)
-	}
-	return filtered, nil
+	return hostBaseURL(host)
 }
 
-func mapGitHubRelease(rel githubRelease) Release {
-	assets := make([]Asset, 0, len(rel.Assets))
-	for _, a := range rel.Assets {
-		assets = append(assets, Asset{
-			Name: a.Name,
-			URL:  a.URL,
-			Size: a.Size,
-		})
-	}
-	return Release{
-		Tag:       rel.TagName,
-		ID:        rel.ID,
-		Published: rel.Published,
-		Assets:    assets,
+func hostBaseURL(host string) string {
+	host = strings.TrimSuffix(host, "/")
+	if strings.Contains(host, "://") {
+		return host
 	}
+	return "https://" + host
 }
 
-type gitlabResolver struct {
-	client *http.Client
-}
-
-type gitlabRelease struct {
-	TagName  string `json:"tag_name"`
-	Released string `json:"released_at"`
-	Assets   struct {
-		Links []gitlabAsset `json:"links"`
-	} `json:"assets"`
-}
-
-type gitlabAsset struct {
-	Name string `json:"name"`
-	URL  string `json:"url"`
+// sourceArchiveAssets returns the synthetic assets exposing a tag's source
+// archives, so that extract actions can use them by name.
+func sourceArchiveAssets(tarball, zipball string) []Asset {
+	return []Asset{
+		{Name: "source.tar.gz", URL: tarball},
+		{Name: "source.zip", URL: zipball},
+	}
 }
 
-func (r *gitlabResolver) ResolveRelease(repo string, version string) (Release, error) {
-	releases, err := r.listReleases(repo)
-	if err != nil {
-		return Release{}, err
-	}
-	if len(releases) == 0 {
-		return Release{}, fmt.Errorf("no releases found for %s", repo)
-	}
-	if version != "" {
-		for _, rel := range releases {
-			if rel.TagName == version {
-				return mapGitLabRelease(rel), nil
+// nextPageURL returns the rel="next" target of an RFC 8288 Link header, as
+// sent by GitHub and Gitea on paginated listings.
+func nextPageURL(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			if len(parts) < 2 {
+				continue
+			}
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				param = strings.TrimSpace(param)
+				if param == `rel="next"` || param == "rel=next" {
+					return target[1 : len(target)-1]
+				}
 			}
 		}
-		return Release{}, fmt.Errorf("version %s not found", version)
 	}
-	sort.Slice(releases, func(i, j int) bool {
-		return compareReleases(releases[i].TagName, releases[j].TagName, parseGitLabTime(releases[i].Released), parseGitLabTime(releases[j].Released)) > 0
-	})
-	return mapGitLabRelease(releases[0]), nil
+	return ""
 }
 
-func (r *gitlabResolver) listReleases(repo string) ([]gitlabRelease, error) {
-	project := url.PathEscape(repo)
-	u := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/releases", project)
-	req, err := http.NewRequest(http.MethodGet, u, nil)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := r.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("gitlab releases: %s", resp.Status)
-	}
-	var releases []gitlabRelease
-	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
-		return nil, err
-	}
-	return releases, nil
+// SelectAsset picks the release asset an asset action refers to: by exact
+// name, by pattern, or with auto by scoring assets for the platform (pattern
+// then narrows the candidates).
+func SelectAsset(release Release, action manifest.AssetAction, platform Platform) (Asset, error) {
+	return selectAsset(release, action, platform, false)
 }
 
-func mapGitLabRelease(rel gitlabRelease) Release {
-	assets := make([]Asset, 0, len(rel.Assets.Links))
-	for _, a := range rel.Assets.Links {
-		assets = append(assets, Asset{
-			Name: a.Name,
-			URL:  a.URL,
-		})
+func selectAsset(release Release, action manifest.AssetAction, platform Platform, archive bool) (Asset, error) {
+	if action.Name != "" {
+		for _, asset := range release.Assets {
+			if asset.Name == action.Name {
+				return asset, nil
+			}
+		}
+		if release.DownloadBase != "" {
+			return Asset{Name: action.Name, URL: release.DownloadBase + "/" + url.PathEscape(action.Name)}, nil
+		}
+		return Asset{}, fmt.Errorf("asset %s not found", action.Name)
 	}
-	return Release{
-		Tag:    rel.TagName,
-		Assets: assets,
+	if release.DownloadBase != "" && len(release.Assets) == 0 {
+		return Asset{}, errNoAssetList
 	}
-}
-
-func compareReleases(tagA, tagB string, timeA, timeB time.Time) int {
-	if score, ok := compareSemver(tagA, tagB); ok {
-		return score
+	if !action.Auto && action.Pattern == "" {
+		return Asset{}, errors.New("asset action requires name, pattern or auto")
 	}
-	if timeA.After(timeB) {
-		return 1
+	var candidates []Asset
+	for _, asset := range release.Assets {
+		if action.Pattern != "" && !manifest.MatchPattern(asset.Name, action.Pattern) {
+			continue
+		}
+		if excluded(asset.Name, action.Exclude) {
+			continue
+		}
+		candidates = append(candidates, asset)
 	}
-	if timeB.After(timeA) {
-		return -1
+	if action.Auto {
+		var err error
+		candidates, err = selectForPlatform(candidates, platform, archive)
+		if err != nil {
+			return Asset{}, err
+		}
+	} else if len(candidates) == 0 {
+		return Asset{}, fmt.Errorf("asset matching %q not found", action.Pattern)
 	}
-	return strings.Compare(tagA, tagB)
-}
-
-func compareSemver(a, b string) (int, bool) {
-	va, oka := parseSemver(a)
-	vb, okb := parseSemver(b)
-	if !oka || !okb {
-		return 0, false
+	if len(candidates) == 1 {
+		return candidates[0], nil
 	}
-	for i := 0; i < 3; i++ {
-		if va[i] > vb[i] {
-			return 1, true
-		}
-		if va[i] < vb[i] {
-			return -1, true
+	return pickAmong(candidates, action.Select, func(names string) error {
+		if action.Auto {
+			return fmt.Errorf("several assets fit %s equally well: %s", platform, names)
 		}
-	}
-	return 0, true
+		return fmt.Errorf("pattern %q matches several assets: %s", action.Pattern, names)
+	})
 }
 
-func parseSemver(tag string) ([3]int, bool) {
-	tag = strings.TrimPrefix(tag, "v")
-	parts := strings.Split(tag, ".")
-	if len(parts) < 2 {
-		return [3]int{}, false
-	}
-	var nums [3]int
-	for i := 0; i < 3 && i < len(parts); i++ {
-		n := 0
-		for _, ch := range parts[i] {
-			if ch < '0' || ch > '9' {
-				break
-			}
-			n = n*10 + int(ch-'0')
+// SelectAssets returns every asset matching the action's pattern and none of
+// its exclude patterns, for actions installing into a targetDir.
+func SelectAssets(release Release, action manifest.AssetAction) ([]Asset, error) {
+	if release.DownloadBase != "" && len(release.Assets) == 0 {
+		return nil, errNoAssetList
+	}
+	var matches []Asset
+	for _, asset := range release.Assets {
+		if manifest.MatchPattern(asset.Name, action.Pattern) && !excluded(asset.Name, action.Exclude) {
+			matches = append(matches, asset)
 		}
-		nums[i] = n
 	}
-	return nums, true
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("asset matching %q not found", action.Pattern)
+	}
+	return matches, nil
 }
 
-func parseGitLabTime(value string) time.Time {
-	if value == "" {
-		return time.Time{}
-	}
-	if t, err := time.Parse(time.RFC3339, value); err == nil {
-		return t
+func excluded(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if manifest.MatchPattern(name, pattern) {
+			return true
+		}
 	}
-	return time.Time{}
+	return false
 }
 
-func SelectAsset(release Release, action manifest.AssetAction) (Asset, error) {
-	if action.Name != "" {
-		for _, asset := range release.Assets {
-			if asset.Name == action.Name {
-				return asset, nil
+// pickAmong resolves several matching assets with the action's select
+// strategy; without one, ambiguity is an error listing the candidates.
+func pickAmong(candidates []Asset, strategy string, ambiguous func(names string) error) (Asset, error) {
+	switch strategy {
+	case "first":
+		return candidates[0], nil
+	case "largest":
+		best := candidates[0]
+		for _, asset := range candidates[1:] {
+			if asset.Size > best.Size {
+				best = asset
 			}
 		}
-		return Asset{}, fmt.Errorf("asset %s not found", action.Name)
-	}
-	if action.Pattern != "" {
-		for _, asset := range release.Assets {
-			if manifest.MatchPattern(asset.Name, action.Pattern) {
-				return asset, nil
+		return best, nil
+	case "newest":
+		best := candidates[0]
+		for _, asset := range candidates[1:] {
+			if asset.Updated.After(best.Updated) {
+				best = asset
 			}
 		}
-		return Asset{}, fmt.Errorf("asset matching %q not found", action.Pattern)
+		return best, nil
+	case "":
+		names := make([]string, 0, len(candidates))
+		for _, asset := range candidates {
+			names = append(names, asset.Name)
+		}
+		return Asset{}, ambiguous(strings.Join(names, ", "))
+	default:
+		return Asset{}, fmt.Errorf("unknown asset select strategy %q", strategy)
 	}
-	return Asset{}, errors.New("asset action requires name or pattern")
 }
 
 func NormalizeRepoRepoName(repo string) string {
@@ -0,0 +1,258 @@
+package source
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// versionScheme orders version strings. compare reports ok=false when either
+// version does not follow the scheme.
+type versionScheme interface {
+	compare(a, b string) (int, bool)
+}
+
+func newVersionScheme(spec string) (versionScheme, error) {
+	switch spec {
+	case "", "semver":
+		return semverScheme{}, nil
+	case "calver":
+		return calverScheme{}, nil
+	case "date":
+		return dateScheme{}, nil
+	case "lexical":
+		return lexicalScheme{}, nil
+	default:
+		re, err := regexp.Compile(spec)
+		if err != nil {
+			return nil, fmt.Errorf("source.versionScheme: %w", err)
+		}
+		if re.NumSubexp() == 0 {
+			return nil, fmt.Errorf("source.versionScheme: regex %q has no capture groups", spec)
+		}
+		return regexScheme{re: re}, nil
+	}
+}
+
+// compareReleases orders two releases by scheme, then by publish date, then
+// lexically by tag.
+func compareReleases(scheme versionScheme, tagA, tagB string, timeA, timeB time.Time) int {
+	if score, ok := scheme.compare(tagA, tagB); ok && score != 0 {
+		return score
+	}
+	if timeA.After(timeB) {
+		return 1
+	}
+	if timeB.After(timeA) {
+		return -1
+	}
+	return strings.Compare(tagA, tagB)
+}
+
+type semver struct {
+	nums  [3]int
+	pre   []string
+	build string
+}
+
+var semverRE = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+
+// parseSemver parses a Semver 2.0 version with an optional "v" prefix. The
+// patch component may be omitted ("v1.29").
+func parseSemver(tag string) (semver, bool) {
+	m := semverRE.FindStringSubmatch(tag)
+	if m == nil {
+		return semver{}, false
+	}
+	var v semver
+	for i := 0; i < 3; i++ {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return semver{}, false
+		}
+		v.nums[i] = n
+	}
+	if m[4] != "" {
+		v.pre = strings.Split(m[4], ".")
+	}
+	v.build = m[5]
+	return v, true
+}
+
+func (v semver) compare(o semver) int {
+	for i := 0; i < 3; i++ {
+		if c := compareInts(v.nums[i], o.nums[i]); c != 0 {
+			return c
+		}
+	}
+	if c := comparePrerelease(v.pre, o.pre); c != 0 {
+		return c
+	}
+	return compareBuild(v.build, o.build)
+}
+
+// comparePrerelease applies Semver 2.0 precedence: a version without
+// prerelease identifiers ranks higher; numeric identifiers compare
+// numerically and rank below alphanumeric ones; a longer list wins a tie.
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, errA := strconv.Atoi(a[i])
+		nb, errB := strconv.Atoi(b[i])
+		switch {
+		case errA == nil && errB == nil:
+			if c := compareInts(na, nb); c != 0 {
+				return c
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInts(len(a), len(b))
+}
+
+var buildSuffixRE = regexp.MustCompile(`^(.*?)(\d+)$`)
+
+// compareBuild orders distro-style build suffixes such as "k3s1" < "k3s2" by
+// their trailing number when the rest is equal. Semver ignores build metadata
+// for precedence, so any other pair ties.
+func compareBuild(a, b string) int {
+	ma := buildSuffixRE.FindStringSubmatch(a)
+	mb := buildSuffixRE.FindStringSubmatch(b)
+	if ma == nil || mb == nil || ma[1] != mb[1] {
+		return 0
+	}
+	na, _ := strconv.Atoi(ma[2])
+	nb, _ := strconv.Atoi(mb[2])
+	return compareInts(na, nb)
+}
+
+// isSemverPrerelease reports whether tag is a semantic version with
+// prerelease identifiers. Sources without a prerelease flag use it to keep
+// release candidates out of the stable channel.
+func isSemverPrerelease(tag string) bool {
+	v, ok := parseSemver(tag)
+	return ok && len(v.pre) > 0
+}
+
+type semverScheme struct{}
+
+func (semverScheme) compare(a, b string) (int, bool) {
+	va, oka := parseSemver(a)
+	vb, okb := parseSemver(b)
+	if !oka || !okb {
+		return 0, false
+	}
+	return va.compare(vb), true
+}
+
+var calverRE = regexp.MustCompile(`^v?\d+(?:[._-]\d+)*$`)
+
+// calverScheme compares dot, dash or underscore separated numeric
+// components such as 2024.05.1 or 24.04.
+type calverScheme struct{}
+
+func (calverScheme) compare(a, b string) (int, bool) {
+	if !calverRE.MatchString(a) || !calverRE.MatchString(b) {
+		return 0, false
+	}
+	return compareNumberLists(splitNumbers(a), splitNumbers(b)), true
+}
+
+func splitNumbers(s string) []int {
+	fields := strings.FieldsFunc(strings.TrimPrefix(s, "v"), func(r rune) bool {
+		return r == '.' || r == '-' || r == '_'
+	})
+	nums := make([]int, 0, len(fields))
+	for _, f := range fields {
+		n, _ := strconv.Atoi(f)
+		nums = append(nums, n)
+	}
+	return nums
+}
+
+func compareNumberLists(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareInts(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(a), len(b))
+}
+
+var dateRE = regexp.MustCompile(`(\d{4})[-._]?(\d{2})[-._]?(\d{2})`)
+
+// dateScheme orders tags by the first YYYY-MM-DD, YYYY.MM.DD or YYYYMMDD
+// date they contain.
+type dateScheme struct{}
+
+func (dateScheme) compare(a, b string) (int, bool) {
+	ma := dateRE.FindStringSubmatch(a)
+	mb := dateRE.FindStringSubmatch(b)
+	if ma == nil || mb == nil {
+		return 0, false
+	}
+	return strings.Compare(strings.Join(ma[1:], ""), strings.Join(mb[1:], "")), true
+}
+
+type lexicalScheme struct{}
+
+func (lexicalScheme) compare(a, b string) (int, bool) {
+	return strings.Compare(a, b), true
+}
+
+// regexScheme compares the capture groups of a regex in order, numerically
+// when both groups are numbers.
+type regexScheme struct {
+	re *regexp.Regexp
+}
+
+func (s regexScheme) compare(a, b string) (int, bool) {
+	ma := s.re.FindStringSubmatch(a)
+	mb := s.re.FindStringSubmatch(b)
+	if ma == nil || mb == nil {
+		return 0, false
+	}
+	for i := 1; i < len(ma); i++ {
+		na, errA := strconv.Atoi(ma[i])
+		nb, errB := strconv.Atoi(mb[i])
+		var c int
+		if errA == nil && errB == nil {
+			c = compareInts(na, nb)
+		} else {
+			c = strings.Compare(ma[i], mb[i])
+		}
+		if c != 0 {
+			return c, true
+		}
+	}
+	return 0, true
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	}
+	return 0
+}
@@ -29,10 +29,14 @@ type Receipt struct {
 }
 
 type ReceiptSource struct {
-	Kind      string `json:"kind"`
-	Repo      string `json:"repo,omitempty"`
-	Tag       string `json:"tag,omitempty"`
-	ReleaseID int64  `json:"releaseId,omitempty"`
+	Kind       string `json:"kind"`
+	Repo       string `json:"repo,omitempty"`
+	Host       string `json:"host,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+	Version    string `json:"version,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
+	Channel    string `json:"channel,omitempty"`
+	ReleaseID  int64  `json:"releaseId,omitempty"`
 }
 
 type Platform struct {
@@ -46,6 +50,7 @@ type Artifact struct {
 	URL    string `json:"url,omitempty"`
 	SHA256 string `json:"sha256,omitempty"`
 	Size   int64  `json:"size,omitempty"`
+	Digest string `json:"digest,omitempty"`
 }
 
 type ReceiptFile struct {
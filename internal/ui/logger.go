@@ -3,6 +3,8 @@ package ui
 import (
 	"fmt"
 	"io"
+	"strings"
+	"sync"
 )
 
 type Level int
@@ -18,6 +20,31 @@ type Logger struct {
 	Writer io.Writer
 }
 
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// AddSecret registers a value (typically an API token) that every logger
+// replaces with "***" before writing.
+func AddSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secrets = append(secrets, secret)
+}
+
+func redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, "***")
+	}
+	return s
+}
+
 func NewLogger(level Level, w io.Writer) Logger {
 	return Logger{Level: level, Writer: w}
 }
@@ -26,12 +53,12 @@ func (l Logger) Infof(format string, args ...any) {
 	if l.Level < LevelNormal || l.Writer == nil {
 		return
 	}
-	fmt.Fprintf(l.Writer, format+"\n", args...)
+	fmt.Fprintln(l.Writer, redact(fmt.Sprintf(format, args...)))
 }
 
 func (l Logger) Verbosef(format string, args ...any) {
 	if l.Level < LevelVerbose || l.Writer == nil {
 		return
 	}
-	fmt.Fprintf(l.Writer, format+"\n", args...)
+	fmt.Fprintln(l.Writer, redact(fmt.Sprintf(format, args...)))
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 
@@ -143,6 +144,8 @@ func main() {
 	}
 
 	var installVersion string
+	var installChannel string
+	var installPlatform string
 	var installAll bool
 	var installForce bool
 	installCmd := &cobra.Command{
@@ -165,13 +168,13 @@ func main() {
 					return err
 				}
 				for _, mf := range mfs {
-					if _, err := manager.Install(mf.Name, ghpm.InstallOptions{Version: installVersion, Force: installForce}); err != nil {
+					if _, err := manager.Install(mf.Name, ghpm.InstallOptions{Version: installVersion, Channel: installChannel, Platform: installPlatform, Force: installForce}); err != nil {
 						return err
 					}
 				}
 				return nil
 			}
-			receipt, err := manager.Install(args[0], ghpm.InstallOptions{Version: installVersion, Force: installForce})
+			receipt, err := manager.Install(args[0], ghpm.InstallOptions{Version: installVersion, Channel: installChannel, Platform: installPlatform, Force: installForce})
 			if err != nil {
 				return err
 			}
@@ -183,7 +186,9 @@ func main() {
 			return nil
 		},
 	}
-	installCmd.Flags().StringVar(&installVersion, "version", "", "version/tag")
+	installCmd.Flags().StringVar(&installVersion, "version", "", "version/tag or range (e.g. ~1.29)")
+	installCmd.Flags().StringVar(&installChannel, "channel", "", "release channel (stable, prerelease or tag regex)")
+	installCmd.Flags().StringVar(&installPlatform, "platform", "", "target platform as os/arch (default: this host)")
 	installCmd.Flags().BoolVar(&installAll, "all", false, "install all")
 	installCmd.Flags().BoolVar(&installForce, "force", false, "overwrite conflicts")
 
@@ -215,6 +220,8 @@ func main() {
 
 	var upgradeAll bool
 	var upgradeDryRun bool
+	var upgradeChannel string
+	var upgradePlatform string
 	upgradeCmd := &cobra.Command{
 		Use:   "upgrade <name>",
 		Short: "Upgrade a package",
@@ -235,7 +242,7 @@ func main() {
 					return err
 				}
 				for _, mf := range mfs {
-					changed, _, err := manager.Upgrade(mf.Name, ghpm.InstallOptions{DryRun: upgradeDryRun})
+					changed, _, err := manager.Upgrade(mf.Name, ghpm.InstallOptions{DryRun: upgradeDryRun, Channel: upgradeChannel, Platform: upgradePlatform})
 					if err != nil {
 						return err
 					}
@@ -245,7 +252,7 @@ func main() {
 				}
 				return nil
 			}
-			changed, receipt, err := manager.Upgrade(args[0], ghpm.InstallOptions{DryRun: upgradeDryRun})
+			changed, receipt, err := manager.Upgrade(args[0], ghpm.InstallOptions{DryRun: upgradeDryRun, Channel: upgradeChannel, Platform: upgradePlatform})
 			if err != nil {
 				return err
 			}
@@ -263,6 +270,8 @@ func main() {
 	}
 	upgradeCmd.Flags().BoolVar(&upgradeAll, "all", false, "upgrade all")
 	upgradeCmd.Flags().BoolVar(&upgradeDryRun, "dry-run", false, "check for upgrades")
+	upgradeCmd.Flags().StringVar(&upgradeChannel, "channel", "", "release channel (stable, prerelease or tag regex)")
+	upgradeCmd.Flags().StringVar(&upgradePlatform, "platform", "", "target platform as os/arch (default: as installed)")
 
 	var selfVersion string
 	selfCmd := &cobra.Command{
@@ -305,6 +314,10 @@ func main() {
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		var verr *ghpm.VerificationError
+		if errors.As(err, &verr) {
+			os.Exit(5)
+		}
 		os.Exit(1)
 	}
 }
@@ -5,63 +5,1633 @@ package tests
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
 )
 
-func TestInstallRemoveRawBinary(t *testing.T) {
+func TestInstallRemoveRawBinary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "ghpm-raw", rawManifest())
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "ghpm-raw")
+
+	binPath := filepath.Join(root, "bin", "ghpm")
+	assertExecutable(t, binPath)
+
+	runGHPM(t, ghpm, cfg, "remove", "ghpm-raw")
+	assertMissing(t, binPath)
+}
+
+func TestInstallRemoveArchive(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "ghpm-archive", archiveManifest())
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "ghpm-archive")
+
+	base := findArchiveBase(t, filepath.Join(root, "opt", "ghpm"))
+	assertExecutable(t, filepath.Join(base, "ghpm"))
+	assertFile(t, filepath.Join(base, "README.md"))
+	assertFile(t, filepath.Join(base, "doc", "manifest-reference.md"))
+	assertFile(t, filepath.Join(base, "examples", "k3s", "package.yaml"))
+	assertOnlyFiles(t, base, []string{
+		"ghpm",
+		"README.md",
+		"doc/manifest-reference.md",
+		"examples/k3s/package.yaml",
+	})
+
+	runGHPM(t, ghpm, cfg, "remove", "ghpm-archive")
+	assertMissing(t, filepath.Join(base, "ghpm"))
+	assertMissing(t, filepath.Join(base, "README.md"))
+	assertMissing(t, filepath.Join(base, "doc", "manifest-reference.md"))
+	assertMissing(t, filepath.Join(base, "examples", "k3s", "package.yaml"))
+}
+
+func TestInstallFromAPIURL(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleasesServer(t,
+		testRelease{tag: "v1.2.0", assets: map[string]string{"tool": "tool 1.2"}},
+		testRelease{tag: "v1.1.0", assets: map[string]string{"tool": "tool 1.1"}},
+	)
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "tool", fmt.Sprintf(`name: tool
+source:
+  kind: github
+  repo: acme/tool
+  apiURL: %s/api/v3
+install:
+  - type: asset
+    name: tool
+    target: "bin/tool"
+    mode: "0755"
+`, server.URL))
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "tool")
+
+	binPath := filepath.Join(root, "bin", "tool")
+	assertExecutable(t, binPath)
+	assertContent(t, binPath, "tool 1.2")
+}
+
+func TestInstallFromGitea(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleasesServer(t,
+		testRelease{tag: "v2.0.0-rc.1", prerelease: true, assets: map[string]string{"tool": "2.0.0-rc.1"}},
+		testRelease{tag: "v1.1.0", assets: map[string]string{"tool": "1.1.0"}},
+		testRelease{tag: "v1.0.0", assets: map[string]string{"tool": "1.0.0"}},
+	)
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	for _, kind := range []string{"gitea", "forgejo"} {
+		writeManifest(t, cfg.packagesDir, kind, fmt.Sprintf(`name: %s
+source:
+  kind: %[1]s
+  repo: acme/tool
+  host: %s
+install:
+  - type: asset
+    name: tool
+    target: /bin/%[1]s
+    mode: "0755"
+`, kind, server.URL))
+	}
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "gitea")
+	assertContent(t, filepath.Join(root, "bin", "gitea"), "1.1.0")
+	runGHPM(t, ghpm, cfg, "install", "forgejo", "--version", "v1.0.0")
+	assertContent(t, filepath.Join(root, "bin", "forgejo"), "1.0.0")
+}
+
+func TestInstallPaginatesReleases(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	// The server lists two releases per page, so the latest stable release
+	// is on page two behind the prereleases and the oldest on page three.
+	server := newReleasesServer(t,
+		testRelease{tag: "v3.0.0-rc.2", prerelease: true, assets: map[string]string{"tool": "3.0.0-rc.2"}},
+		testRelease{tag: "v3.0.0-rc.1", prerelease: true, assets: map[string]string{"tool": "3.0.0-rc.1"}},
+		testRelease{tag: "v2.1.0", assets: map[string]string{"tool": "2.1.0"}},
+		testRelease{tag: "v2.0.0", assets: map[string]string{"tool": "2.0.0"}},
+		testRelease{tag: "v1.0.0", assets: map[string]string{"tool": "1.0.0"}},
+	)
+	const (
+		githubReleases = "/api/v3/repos/acme/tool/releases"
+		gitlabReleases = "/api/v4/projects/acme/tool/releases"
+	)
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "github", githubManifest("github", server, ""))
+	writeManifest(t, cfg.packagesDir, "gitlab", fmt.Sprintf(`name: gitlab
+source:
+  kind: gitlab
+  repo: acme/tool
+  host: %s
+install:
+  - type: asset
+    name: tool
+    target: /bin/gitlab
+    mode: "0755"
+`, server.URL))
+
+	ghpm := buildBinary(t)
+
+	// An explicit version stops at the page listing it.
+	runGHPM(t, ghpm, cfg, "install", "github", "--version", "v2.1.0")
+	assertContent(t, filepath.Join(root, "bin", "github"), "2.1.0")
+	if got := server.requestCount(githubReleases); got != 2 {
+		t.Fatalf("requested %d release pages, want 2", got)
+	}
+	runGHPM(t, ghpm, cfg, "install", "gitlab", "--version", "v1.0.0")
+	assertContent(t, filepath.Join(root, "bin", "gitlab"), "1.0.0")
+	if got := server.requestCount(gitlabReleases); got != 3 {
+		t.Fatalf("requested %d GitLab release pages, want 3", got)
+	}
+
+	// The latest release needs every page.
+	root = t.TempDir()
+	cfg = newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "github", githubManifest("github", server, ""))
+	runGHPM(t, ghpm, cfg, "install", "github")
+	assertContent(t, filepath.Join(root, "bin", "github"), "2.1.0")
+	if got := server.requestCount(githubReleases); got != 2+3 {
+		t.Fatalf("requested %d release pages, want 3 more", got-2)
+	}
+}
+
+func TestInstallSendsCredentials(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newTLSReleasesServer(t, testRelease{tag: "v1.0.0", assets: map[string]string{"tool": "1.0.0"}})
+	const (
+		releases = "/api/v3/repos/acme/tool/releases"
+		download = "/acme/tool/releases/download/v1.0.0/tool"
+	)
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(netrc, []byte("machine 127.0.0.1\n  login ci\n  password netrc-secret\n"), 0o600); err != nil {
+		t.Fatalf("write netrc: %v", err)
+	}
+
+	ghpm := buildBinary(t)
+
+	// A token from the config file wins over netrc, for both API requests
+	// and downloads, and never shows up in verbose output.
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	cfg.env = []string{"NETRC=" + netrc}
+	trustServers(t, &cfg, server.Server)
+	config := "credentials:\n  127.0.0.1:\n    token: config-secret\n"
+	if err := os.WriteFile(cfg.configPath, []byte(config), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	writeManifest(t, cfg.packagesDir, "tool", githubManifest("tool", server, ""))
+
+	output := runGHPM(t, ghpm, cfg, "--verbose", "install", "tool")
+	if strings.Contains(output, "config-secret") {
+		t.Fatalf("verbose output leaks the token:\n%s", output)
+	}
+	for _, path := range []string{releases, download} {
+		if got := server.authorization(path); got != "Bearer config-secret" {
+			t.Fatalf("%s: Authorization %q, want the config token", path, got)
+		}
+	}
+	assertContent(t, filepath.Join(root, "bin", "tool"), "1.0.0")
+
+	// Without a configured token, netrc provides basic auth.
+	root = t.TempDir()
+	cfg = newTestLayout(t, root)
+	cfg.env = []string{"NETRC=" + netrc}
+	trustServers(t, &cfg, server.Server)
+	writeManifest(t, cfg.packagesDir, "tool", githubManifest("tool", server, ""))
+
+	runGHPM(t, ghpm, cfg, "install", "tool")
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("ci:netrc-secret"))
+	if got := server.authorization(releases); got != basic {
+		t.Fatalf("Authorization %q, want netrc credentials", got)
+	}
+
+	// GITHUB_TOKEN applies to the default GitHub instance, whether it is
+	// configured as a URL with a scheme and port or only as an API URL.
+	for _, defaults := range []string{"host: " + server.URL, "apiURL: " + server.URL + "/api/v3"} {
+		root = t.TempDir()
+		cfg = newTestLayout(t, root)
+		cfg.env = []string{"NETRC=" + netrc, "GITHUB_TOKEN=env-secret"}
+		trustServers(t, &cfg, server.Server)
+		if err := os.WriteFile(cfg.configPath, []byte("sources:\n  github:\n    "+defaults+"\n"), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		writeManifest(t, cfg.packagesDir, "tool", `name: tool
+source:
+  kind: github
+  repo: acme/tool
+install:
+  - type: asset
+    name: tool
+    target: /bin/tool
+    mode: "0755"
+`)
+
+		runGHPM(t, ghpm, cfg, "install", "tool")
+		if got := server.authorization(releases); got != "Bearer env-secret" {
+			t.Fatalf("%s: Authorization %q, want GITHUB_TOKEN", defaults, got)
+		}
+	}
+}
+
+func TestInstallPrivateAsset(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	// The asset API redirects to a storage host, which must not receive the
+	// token. It listens on [::1] so that it is another host than the API.
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	}
+	storage := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			http.Error(w, "unexpected credentials", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, "private")
+	}))
+	storage.Listener.Close()
+	storage.Listener = listener
+	storage.StartTLS()
+	t.Cleanup(storage.Close)
+
+	server := newTLSReleasesServer(t, testRelease{tag: "v1.0.0", assets: map[string]string{"tool": "private"}})
+	server.handle("/acme/tool/releases/download/v1.0.0/tool", http.NotFound)
+	server.handle("/api/v3/repos/acme/tool/releases/assets/v1.0.0/tool", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/octet-stream" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, storage.URL+"/tool", http.StatusFound)
+	})
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	trustServers(t, &cfg, server.Server, storage)
+	if err := os.WriteFile(cfg.configPath, []byte("credentials:\n  127.0.0.1:\n    token: secret\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	writeManifest(t, cfg.packagesDir, "tool", githubManifest("tool", server, "  private: true\n"))
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "tool")
+	assertContent(t, filepath.Join(root, "bin", "tool"), "private")
+}
+
+func TestInstallRetriesTransientFailures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleaseServer(t, "v1.0.0", map[string]string{"tool": "1.0.0"})
+	const (
+		releases = "/api/v3/repos/acme/tool/releases"
+		download = "/acme/tool/releases/download/v1.0.0/tool"
+	)
+	server.fail(releases, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	server.fail(download, http.StatusBadGateway)
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "tool", githubManifest("tool", server, ""))
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "tool")
+	assertContent(t, filepath.Join(root, "bin", "tool"), "1.0.0")
+	if got := server.requestCount(releases); got != 3 {
+		t.Fatalf("requested releases %d times, want 3", got)
+	}
+	if got := server.requestCount(download); got != 2 {
+		t.Fatalf("requested the asset %d times, want 2", got)
+	}
+
+	// An exhausted quota resetting too late to wait for fails right away.
+	reset := time.Now().Add(2 * time.Hour)
+	server.handle(releases, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		http.Error(w, "API rate limit exceeded", http.StatusForbidden)
+	})
+	root = t.TempDir()
+	cfg = newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "tool", githubManifest("tool", server, ""))
+
+	output := runGHPMExpectExit(t, ghpm, cfg, 1, "install", "tool")
+	if want := "github releases: rate limited until " + reset.Format("15:04"); !strings.Contains(output, want) {
+		t.Fatalf("output lacks %q:\n%s", want, output)
+	}
+}
+
+func TestReleaseListingsAreCached(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleaseServer(t, "v1.0.0", map[string]string{"tool": "1.0.0", "tool.1": "manual"})
+	const releases = "/api/v3/repos/acme/tool/releases"
+	manifest := githubManifest("tool", server, "") + `  - type: asset
+    name: tool.1
+    target: /share/man/man1/tool.1
+`
+
+	ghpm := buildBinary(t)
+
+	// One lookup serves every action of a run, and later runs within the
+	// TTL send no request at all.
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "tool", manifest)
+	runGHPM(t, ghpm, cfg, "install", "tool")
+	assertContent(t, filepath.Join(root, "share", "man", "man1", "tool.1"), "manual")
+	runGHPM(t, ghpm, cfg, "upgrade", "tool", "--dry-run")
+	if got := server.requestCount(releases); got != 1 {
+		t.Fatalf("requested releases %d times, want 1", got)
+	}
+
+	// Listings may come from private repositories.
+	err := filepath.WalkDir(filepath.Join(cfg.cacheDir, "releases"), func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		want := os.FileMode(0o600)
+		if d.IsDir() {
+			want = 0o700
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s has mode %v, want %v", path, info.Mode().Perm(), want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk release cache: %v", err)
+	}
+
+	// With no TTL, every run revalidates and gets 304 Not Modified.
+	root = t.TempDir()
+	cfg = newTestLayout(t, root)
+	if err := os.WriteFile(cfg.configPath, []byte("cache:\n  releaseTTLSeconds: 0\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	writeManifest(t, cfg.packagesDir, "tool", manifest)
+	runGHPM(t, ghpm, cfg, "install", "tool")
+	runGHPM(t, ghpm, cfg, "upgrade", "tool", "--dry-run")
+	if got := server.requestCount(releases); got != 1+2 {
+		t.Fatalf("requested releases %d times, want 2 more", got-1)
+	}
+	if got := server.notModifiedCount(); got != 1 {
+		t.Fatalf("got %d revalidations, want 1", got)
+	}
+}
+
+func TestInstallFollowsChannels(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleasesServer(t,
+		testRelease{tag: "v2.0.0-rc.1", prerelease: true, assets: map[string]string{"tool": "2.0.0-rc.1"}},
+		testRelease{tag: "v1.1.0", assets: map[string]string{"tool": "1.1.0"}},
+		testRelease{tag: "v1.0.1", assets: map[string]string{"tool": "1.0.1"}},
+		testRelease{tag: "v1.0.0", assets: map[string]string{"tool": "1.0.0"}},
+	)
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "stable", githubManifest("stable", server, ""))
+	writeManifest(t, cfg.packagesDir, "rc", githubManifest("rc", server, "  channel: prerelease\n"))
+	writeManifest(t, cfg.packagesDir, "pinned", githubManifest("pinned", server, "  channel: '^v1\\.0\\.0$'\n"))
+	writeManifest(t, cfg.packagesDir, "range", githubManifest("range", server, "version: ~1.0\n"))
+	writeManifest(t, cfg.packagesDir, "override", githubManifest("override", server, ""))
+	writeManifest(t, cfg.packagesDir, "gitlab", fmt.Sprintf(`name: gitlab
+source:
+  kind: gitlab
+  repo: acme/tool
+  host: %s
+install:
+  - type: asset
+    name: tool
+    target: /bin/gitlab
+    mode: "0755"
+`, server.URL))
+
+	ghpm := buildBinary(t)
+
+	for _, name := range []string{"stable", "rc", "pinned", "range", "gitlab"} {
+		runGHPM(t, ghpm, cfg, "install", name)
+	}
+	runGHPM(t, ghpm, cfg, "install", "override", "--channel", "prerelease")
+	for name, want := range map[string]string{
+		"stable":   "1.1.0",
+		"rc":       "2.0.0-rc.1",
+		"pinned":   "1.0.0",
+		"range":    "1.0.1",
+		"gitlab":   "1.1.0",
+		"override": "2.0.0-rc.1",
+	} {
+		assertContent(t, filepath.Join(root, "bin", name), want)
+	}
+
+	// Upgrades keep following the channel given at install time.
+	if output := runGHPM(t, ghpm, cfg, "upgrade", "override", "--dry-run"); !strings.Contains(output, "already up to date") {
+		t.Fatalf("dry-run leaves the prerelease channel:\n%s", output)
+	}
+	runGHPM(t, ghpm, cfg, "upgrade", "override")
+	assertContent(t, filepath.Join(root, "bin", "override"), "2.0.0-rc.1")
+}
+
+func TestInstallFromMonorepoTags(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleasesServer(t,
+		testRelease{tag: "sdk/v0.9.0", assets: map[string]string{"sdk_0.9.0_linux_amd64": "sdk"}},
+		testRelease{tag: "cli/v1.2.3", assets: map[string]string{"tool_1.2.3_linux_amd64": "1.2.3"}},
+		testRelease{tag: "cli/v1.2.2", assets: map[string]string{"tool_1.2.2_linux_amd64": "1.2.2"}},
+	)
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "tool", fmt.Sprintf(`name: tool
+%s  tagPattern: '^cli/'
+  versionPattern: '^cli/v(?P<version>.+)$'
+install:
+  - type: asset
+    name: "tool_{version}_{os}_{arch}"
+    target: /bin/tool
+    mode: "0755"
+  - type: file
+    path: tag
+    target: "/share/tool/{tag|replace:/=-}"
+`, githubSource(server)))
+	if err := os.WriteFile(filepath.Join(cfg.packagesDir, "tool", "tag"), []byte("tag"), 0o644); err != nil {
+		t.Fatalf("write package file: %v", err)
+	}
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "tool", "--platform", "linux/amd64")
+	assertContent(t, filepath.Join(root, "bin", "tool"), "1.2.3")
+	assertContent(t, filepath.Join(root, "share", "tool", "cli-v1.2.3"), "tag")
+
+	// --version accepts the derived version as well as the tag.
+	runGHPM(t, ghpm, cfg, "install", "tool", "--platform", "linux/amd64", "--version", "1.2.2")
+	assertContent(t, filepath.Join(root, "bin", "tool"), "1.2.2")
+}
+
+func TestInstallDiscoversHTTPVersions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleasesServer(t)
+	server.handle("/index.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"versions": {"1.4.2": {"version": "1.4.2"}, "1.5.0": {"version": "1.5.0"}, "1.6.0-beta1": {"version": "1.6.0-beta1"}}}`)
+	})
+	server.handle("/dist/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="tool_1.4.2/">tool_1.4.2/</a> <a href="tool_1.10.0/">tool_1.10.0/</a> <a href="tool_1.9.1/">tool_1.9.1/</a>`)
+	})
+	server.handle("/latest.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "1.4.2")
+	})
+	for _, version := range []string{"1.4.2", "1.5.0", "1.10.0"} {
+		server.handle("/dl/"+version+"/tool", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, version)
+		})
+	}
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	if err := os.WriteFile(cfg.configPath, []byte("cache:\n  releaseTTLSeconds: 0\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	for name, discovery := range map[string]string{
+		"json": "type: json\n    url: %s/index.json\n    path: versions.*.version",
+		"html": "type: html\n    url: %s/dist/\n    pattern: 'href=\"tool_(\\d+\\.\\d+\\.\\d+)/\"'",
+		"text": "type: text\n    url: %s/latest.txt",
+	} {
+		writeManifest(t, cfg.packagesDir, name, fmt.Sprintf(`name: %s
+source:
+  kind: http
+  discovery:
+    %s
+install:
+  - type: url
+    url: "%s/dl/{version}/tool"
+    target: /bin/%[1]s
+    mode: "0755"
+`, name, fmt.Sprintf(discovery, server.URL), server.URL))
+	}
+
+	ghpm := buildBinary(t)
+
+	for name, want := range map[string]string{"json": "1.5.0", "html": "1.10.0", "text": "1.4.2"} {
+		runGHPM(t, ghpm, cfg, "install", name)
+		assertContent(t, filepath.Join(root, "bin", name), want)
+	}
+
+	server.handle("/latest.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "1.5.0")
+	})
+	runGHPM(t, ghpm, cfg, "upgrade", "text")
+	assertContent(t, filepath.Join(root, "bin", "text"), "1.5.0")
+}
+
+func TestInstallFromFeed(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	published := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := newReleasesServer(t,
+		testRelease{tag: "v2.0.0-rc.1", prerelease: true, published: published.Add(2 * time.Hour), assets: map[string]string{"tool": "2.0.0-rc.1"}},
+		testRelease{tag: "v1.29.0", published: published.Add(time.Hour), assets: map[string]string{"tool": "1.29.0"}},
+		testRelease{tag: "v1.28.0", published: published, assets: map[string]string{"tool": "1.28.0"}},
+	)
+	const (
+		latest = "/acme/tool/releases/latest"
+		feed   = "/acme/tool/releases.atom"
+	)
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "stable", githubManifest("stable", server, "  from: feed\n"))
+	writeManifest(t, cfg.packagesDir, "rc", githubManifest("rc", server, "  from: feed\n  channel: prerelease\n"))
+	writeManifest(t, cfg.packagesDir, "pinned", githubManifest("pinned", server, "  from: feed\n"))
+
+	ghpm := buildBinary(t)
+
+	// The latest release comes from the redirect alone.
+	runGHPM(t, ghpm, cfg, "install", "stable")
+	assertContent(t, filepath.Join(root, "bin", "stable"), "1.29.0")
+	if server.requestCount(latest) != 1 || server.requestCount(feed) != 0 {
+		t.Fatalf("latest resolved with %d redirects and %d feed reads, want 1 and 0",
+			server.requestCount(latest), server.requestCount(feed))
+	}
+
+	// Channels need the feed.
+	runGHPM(t, ghpm, cfg, "install", "rc")
+	assertContent(t, filepath.Join(root, "bin", "rc"), "2.0.0-rc.1")
+	if got := server.requestCount(feed); got != 1 {
+		t.Fatalf("read the feed %d times, want 1", got)
+	}
+
+	// An explicit version needs neither.
+	runGHPM(t, ghpm, cfg, "install", "pinned", "--version", "v1.28.0")
+	assertContent(t, filepath.Join(root, "bin", "pinned"), "1.28.0")
+	if server.requestCount(latest) != 1 || server.requestCount(feed) != 1 {
+		t.Fatal("an explicit version was looked up")
+	}
+
+	for _, path := range []string{"/api/v3/repos/acme/tool/releases", "/api/v3/repos/acme/tool/releases/latest"} {
+		if got := server.requestCount(path); got != 0 {
+			t.Fatalf("requested %s %d times, want none", path, got)
+		}
+	}
+}
+
+func TestInstallUpstreamLatest(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	// A backport published after the newer line is marked as latest.
+	published := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := newReleasesServer(t,
+		testRelease{tag: "v1.28.9", latest: true, published: published.Add(time.Hour), assets: map[string]string{"tool": "1.28.9"}},
+		testRelease{tag: "v1.29.0", published: published, assets: map[string]string{"tool": "1.29.0"}},
+	)
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "upstream", githubManifest("upstream", server, "  latest: upstream\n"))
+	writeManifest(t, cfg.packagesDir, "highest", githubManifest("highest", server, ""))
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "upstream")
+	assertContent(t, filepath.Join(root, "bin", "upstream"), "1.28.9")
+	runGHPM(t, ghpm, cfg, "install", "highest")
+	assertContent(t, filepath.Join(root, "bin", "highest"), "1.29.0")
+
+	output := runGHPM(t, ghpm, cfg, "upgrade", "upstream", "--dry-run")
+	if want := "upstream latest release is v1.28.9, but v1.29.0 is the highest version"; !strings.Contains(output, want) {
+		t.Fatalf("output lacks %q:\n%s", want, output)
+	}
+}
+
+func TestInstallPicksAssetForPlatform(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleaseServer(t, "v1.0.0", map[string]string{
+		"tool-linux-x86_64-gnu":        "linux-gnu",
+		"tool-linux-x86_64-musl":       "linux-musl",
+		"tool-linux-x86_64-gnu.tar.gz": "archive",
+		"tool-linux-x86_64-gnu.sha256": "checksum",
+		"tool-linux-arm64-gnu":         "linux-arm64",
+		"tool-darwin-x86_64":           "darwin",
+		"tool_1.0.0_amd64.deb":         "package",
+	})
+	manifest := fmt.Sprintf(`name: tool
+%sinstall:
+  - type: asset
+    auto: true
+    target: /bin/tool
+    mode: "0755"
+`, githubSource(server))
+
+	ghpm := buildBinary(t)
+
+	for _, tc := range []struct {
+		platform string
+		musl     bool
+		want     string
+	}{
+		{platform: "linux/amd64", want: "linux-gnu"},
+		{platform: "linux/amd64", musl: true, want: "linux-musl"},
+		{platform: "linux/arm64", want: "linux-arm64"},
+		{platform: "darwin/amd64", want: "darwin"},
+	} {
+		root := t.TempDir()
+		cfg := newTestLayout(t, root)
+		writeManifest(t, cfg.packagesDir, "tool", manifest)
+		if tc.musl {
+			if err := os.MkdirAll(filepath.Join(root, "lib"), 0o755); err != nil {
+				t.Fatalf("create lib: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(root, "lib", "ld-musl-x86_64.so.1"), nil, 0o755); err != nil {
+				t.Fatalf("write musl loader: %v", err)
+			}
+		}
+		runGHPM(t, ghpm, cfg, "install", "tool", "--platform", tc.platform)
+		assertContent(t, filepath.Join(root, "bin", "tool"), tc.want)
+	}
+}
+
+func TestInstallRejectsAmbiguousPatterns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleaseServer(t, "v1.0.0", map[string]string{
+		"tool-linux":           "a larger binary",
+		"tool-linux.sha256":    "checksum",
+		"tool-linux.sbom.json": "{}",
+	})
+	manifest := func(name, fields string) string {
+		return fmt.Sprintf(`name: %s
+%sinstall:
+  - type: asset
+    pattern: linux
+%s    target: /bin/%[1]s
+`, name, githubSource(server), fields)
+	}
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "ambiguous", manifest("ambiguous", ""))
+	writeManifest(t, cfg.packagesDir, "excluded", manifest("excluded", "    exclude: ['\\.sha256$', '\\.json$']\n"))
+	writeManifest(t, cfg.packagesDir, "largest", manifest("largest", "    select: largest\n"))
+
+	ghpm := buildBinary(t)
+
+	output := runGHPMExpectExit(t, ghpm, cfg, 1, "install", "ambiguous")
+	if want := `pattern "linux" matches several assets: tool-linux, tool-linux.sbom.json, tool-linux.sha256`; !strings.Contains(output, want) {
+		t.Fatalf("output lacks %q:\n%s", want, output)
+	}
+	assertMissing(t, filepath.Join(root, "bin", "ambiguous"))
+
+	runGHPM(t, ghpm, cfg, "install", "excluded")
+	assertContent(t, filepath.Join(root, "bin", "excluded"), "a larger binary")
+	runGHPM(t, ghpm, cfg, "install", "largest")
+	assertContent(t, filepath.Join(root, "bin", "largest"), "a larger binary")
+}
+
+func TestInstallAssetsIntoTargetDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleaseServer(t, "v1.0.0", map[string]string{
+		"alpha.so":       "alpha",
+		"beta.so":        "beta",
+		"beta.so.sha256": "0000",
+	})
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "plugins", fmt.Sprintf(`name: plugins
+%sinstall:
+  - type: asset
+    pattern: '\.so$'
+    targetDir: lib/plugins
+    targetName: "{version}-{asset}"
+    mode: "0644"
+`, githubSource(server)))
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "plugins")
+	assertContent(t, filepath.Join(root, "lib", "plugins", "v1.0.0-alpha.so"), "alpha")
+	assertContent(t, filepath.Join(root, "lib", "plugins", "v1.0.0-beta.so"), "beta")
+
+	runGHPM(t, ghpm, cfg, "remove", "plugins")
+	assertMissing(t, filepath.Join(root, "lib", "plugins", "v1.0.0-alpha.so"))
+	assertMissing(t, filepath.Join(root, "lib", "plugins", "v1.0.0-beta.so"))
+}
+
+func TestInstallOrdersBuildSuffixes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	// The k3s2 rebuild is listed and published before k3s1, so only the
+	// build suffix can rank it higher.
+	published := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := newReleasesServer(t,
+		testRelease{tag: "v1.35.0+k3s2", published: published, assets: map[string]string{"tool": "k3s2"}},
+		testRelease{tag: "v1.35.0+k3s1", published: published.Add(time.Hour), assets: map[string]string{"tool": "k3s1"}},
+		testRelease{tag: "v1.34.9+k3s10", published: published.Add(2 * time.Hour), assets: map[string]string{"tool": "old"}},
+	)
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "tool", githubManifest("tool", server, ""))
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "tool")
+	assertContent(t, filepath.Join(root, "bin", "tool"), "k3s2")
+}
+
+func TestUpgradeStaysWithinInstalledRange(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleasesServer(t,
+		testRelease{tag: "v1.30.0", assets: map[string]string{"tool": "1.30.0"}},
+		testRelease{tag: "v1.29.4", assets: map[string]string{"tool": "1.29.4"}},
+	)
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "tool", githubManifest("tool", server, ""))
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "tool", "--version", "~1.29")
+	assertContent(t, filepath.Join(root, "bin", "tool"), "1.29.4")
+
+	// A patch release appears upstream, along with the newer line.
+	server = newReleasesServer(t,
+		testRelease{tag: "v1.30.1", assets: map[string]string{"tool": "1.30.1"}},
+		testRelease{tag: "v1.30.0", assets: map[string]string{"tool": "1.30.0"}},
+		testRelease{tag: "v1.29.5", assets: map[string]string{"tool": "1.29.5"}},
+		testRelease{tag: "v1.29.4", assets: map[string]string{"tool": "1.29.4"}},
+	)
+	writeManifest(t, cfg.packagesDir, "tool", githubManifest("tool", server, ""))
+
+	if output := runGHPM(t, ghpm, cfg, "upgrade", "tool"); !strings.Contains(output, "upgraded tool to v1.29.5") {
+		t.Fatalf("unexpected upgrade output:\n%s", output)
+	}
+	assertContent(t, filepath.Join(root, "bin", "tool"), "1.29.5")
+	if output := runGHPM(t, ghpm, cfg, "upgrade", "tool", "--dry-run"); !strings.Contains(output, "already up to date") {
+		t.Fatalf("dry-run leaves the range:\n%s", output)
+	}
+}
+
+func TestInstallExpandsTemplates(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleaseServer(t, "v1.2.3", map[string]string{
+		"tool-1.2.3-Linux-x86_64.tar.gz": "unused",
+		"tool-1.2.3-Linux-x86_64":        "binary",
+		"tool-12-notes":                  "notes",
+	})
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	cfg.env = []string{"TOOL_SUFFIX=-cli"}
+	writeManifest(t, cfg.packagesDir, "tool", fmt.Sprintf(`name: tool
+%sinstall:
+  - type: asset
+    name: "tool-{version|trimprefix:v}-{os|title}-{arch|map:amd64=x86_64,arm64=aarch64}"
+    target: "/{env.TOOL_PREFIX|default:opt}/bin/{name|upper}{env.TOOL_SUFFIX}"
+    mode: "0755"
+  - type: asset
+    pattern: '^tool-\d{2}-notes$'
+    target: /opt/share/{repo|replace:/=-}.txt
+`, githubSource(server)))
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "tool", "--platform", "linux/amd64")
+	assertContent(t, filepath.Join(root, "opt", "bin", "TOOL-cli"), "binary")
+	assertContent(t, filepath.Join(root, "opt", "share", "acme-tool.txt"), "notes")
+
+	// A misspelled filter on a known variable is rejected when the manifest
+	// is loaded instead of being left in the asset name.
+	writeManifest(t, cfg.packagesDir, "typo", fmt.Sprintf(`name: typo
+%sinstall:
+  - type: asset
+    name: "tool-{version|trimprefx:v}"
+    target: /bin/typo
+`, githubSource(server)))
+	output := runGHPMExpectExit(t, ghpm, cfg, 1, "install", "typo")
+	if !strings.Contains(output, `install[0]: template {version|trimprefx:v}: unknown filter "trimprefx"`) {
+		t.Fatalf("unexpected error:\n%s", output)
+	}
+}
+
+func TestInstallFromTagsDatesUnorderedTags(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	// Nightly tags are not semver, so they are ordered by commit date: the
+	// first listed tag is the newest even though it sorts first by name.
+	published := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var releases []testRelease
+	for i := 0; i < 25; i++ {
+		tag := fmt.Sprintf("nightly-%02d", i)
+		releases = append(releases, testRelease{
+			tag:       tag,
+			published: published.Add(-time.Duration(i) * time.Hour),
+			assets:    map[string]string{"source.tar.gz": tag},
+		})
+	}
+	server := newReleasesServer(t, releases...)
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "tool", fmt.Sprintf(`name: tool
+source:
+  kind: github-tags
+  repo: acme/tool
+  host: %s
+install:
+  - type: asset
+    name: source.tar.gz
+    target: /src/tool.tar.gz
+`, server.URL))
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "tool")
+	assertContent(t, filepath.Join(root, "src", "tool.tar.gz"), "nightly-00")
+
+	commits := 0
+	for _, rel := range releases {
+		commits += server.requestCount("/api/v3/repos/acme/tool/commits/" + rel.tag)
+	}
+	if commits != 20 {
+		t.Fatalf("fetched %d commit dates, want the first 20 tags only", commits)
+	}
+}
+
+func TestInstallConditionalActions(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("integration tests require unix-like filesystem semantics")
 	}
 
 	root := t.TempDir()
 	cfg := newTestLayout(t, root)
-	writeManifest(t, cfg.packagesDir, "ghpm-raw", rawManifest())
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0o755); err != nil {
+		t.Fatalf("create etc: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "etc", "os-release"), []byte("ID=raspbian\nID_LIKE=debian\n"), 0o644); err != nil {
+		t.Fatalf("write os-release: %v", err)
+	}
+	writeManifest(t, cfg.packagesDir, "cond", fmt.Sprintf(`name: cond
+install:
+  - type: file
+    path: files/conf
+    target: etc/cond/native.conf
+    when:
+      os: %s
+      arch: [%s]
+  - type: file
+    path: files/conf
+    target: etc/cond/other.conf
+    when:
+      os: plan9
+  - type: file
+    path: files/conf
+    target: etc/cond/debian.conf
+    when:
+      distro: debian
+`, runtime.GOOS, runtime.GOARCH))
+	filesDir := filepath.Join(cfg.packagesDir, "cond", "files")
+	if err := os.MkdirAll(filesDir, 0o755); err != nil {
+		t.Fatalf("create files dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(filesDir, "conf"), []byte("conf"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
 
 	ghpm := buildBinary(t)
 
-	runGHPM(t, ghpm, cfg, "install", "ghpm-raw")
+	runGHPM(t, ghpm, cfg, "install", "cond")
+	assertFile(t, filepath.Join(root, "etc", "cond", "native.conf"))
+	assertFile(t, filepath.Join(root, "etc", "cond", "debian.conf"))
+	assertMissing(t, filepath.Join(root, "etc", "cond", "other.conf"))
 
-	binPath := filepath.Join(root, "bin", "ghpm")
-	assertExecutable(t, binPath)
+	receipt, err := os.ReadFile(filepath.Join(cfg.stateDir, "receipts", "cond.json"))
+	if err != nil {
+		t.Fatalf("read receipt: %v", err)
+	}
+	if bytes.Contains(receipt, []byte("other.conf")) {
+		t.Fatalf("skipped action recorded in receipt:\n%s", receipt)
+	}
+}
 
-	runGHPM(t, ghpm, cfg, "remove", "ghpm-raw")
-	assertMissing(t, binPath)
+func TestInstallForOtherPlatform(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+	arch := "arm64"
+	if runtime.GOARCH == arch {
+		arch = "amd64"
+	}
+
+	server := newReleaseServer(t, "v1.0.0", map[string]string{
+		"tool-linux-amd64": "amd64",
+		"tool-linux-arm64": "arm64",
+		"checksums.txt":    "",
+	})
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	marker := filepath.Join(root, "hook-ran")
+	writeManifest(t, cfg.packagesDir, "tool", fmt.Sprintf(`name: tool
+%sinstall:
+  - type: asset
+    auto: true
+    target: "bin/tool-{arch}"
+    mode: "0755"
+postInstall:
+  - touch %s
+`, githubSource(server), marker))
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "tool", "--platform", "linux/"+arch)
+
+	assertContent(t, filepath.Join(root, "bin", "tool-"+arch), arch)
+	assertMissing(t, marker)
+
+	receipt, err := os.ReadFile(filepath.Join(cfg.stateDir, "receipts", "tool.json"))
+	if err != nil {
+		t.Fatalf("read receipt: %v", err)
+	}
+	if !bytes.Contains(receipt, []byte(`"arch": "`+arch+`"`)) {
+		t.Fatalf("receipt does not record %s:\n%s", arch, receipt)
+	}
 }
 
-func TestInstallRemoveArchive(t *testing.T) {
+func TestInstallVerifiesChecksums(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("integration tests require unix-like filesystem semantics")
 	}
 
+	server := newReleaseServer(t, "v1.0.0", map[string]string{
+		"tool":          "tool 1.0",
+		"checksums.txt": fmt.Sprintf("%x  tool\n", sha256.Sum256([]byte("tool 1.0"))),
+		"tool.sha256":   fmt.Sprintf("%x\n", sha256.Sum256([]byte("tampered"))),
+	})
+
 	root := t.TempDir()
 	cfg := newTestLayout(t, root)
-	writeManifest(t, cfg.packagesDir, "ghpm-archive", archiveManifest())
+	writeManifest(t, cfg.packagesDir, "good", githubManifest("good", server, "checksums:\n  name: checksums.txt\n"))
+	writeManifest(t, cfg.packagesDir, "bad", githubManifest("bad", server, "checksums:\n  name: \"{asset}.sha256\"\n"))
 
 	ghpm := buildBinary(t)
 
-	runGHPM(t, ghpm, cfg, "install", "ghpm-archive")
+	runGHPM(t, ghpm, cfg, "install", "good")
+	assertExecutable(t, filepath.Join(root, "bin", "good"))
 
-	base := findArchiveBase(t, filepath.Join(root, "opt", "ghpm"))
-	assertExecutable(t, filepath.Join(base, "ghpm"))
-	assertFile(t, filepath.Join(base, "README.md"))
-	assertFile(t, filepath.Join(base, "doc", "manifest-reference.md"))
-	assertFile(t, filepath.Join(base, "examples", "k3s", "package.yaml"))
-	assertOnlyFiles(t, base, []string{
-		"ghpm",
-		"README.md",
-		"doc/manifest-reference.md",
-		"examples/k3s/package.yaml",
+	runGHPMExpectExit(t, ghpm, cfg, 5, "install", "bad")
+	assertMissing(t, filepath.Join(root, "bin", "bad"))
+}
+
+func TestInstallPinnedDigests(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleasesServer(t)
+	server.handle("/tool.service", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "unit v1")
 	})
 
-	runGHPM(t, ghpm, cfg, "remove", "ghpm-archive")
-	assertMissing(t, filepath.Join(base, "ghpm"))
-	assertMissing(t, filepath.Join(base, "README.md"))
-	assertMissing(t, filepath.Join(base, "doc", "manifest-reference.md"))
-	assertMissing(t, filepath.Join(base, "examples", "k3s", "package.yaml"))
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	manifest := `name: %[1]s
+install:
+  - type: url
+    url: %[2]s/tool.service
+    target: "etc/%[1]s.service"
+    sha256:
+      %[3]s: %[4]x
+`
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	writeManifest(t, cfg.packagesDir, "v1", fmt.Sprintf(manifest, "v1", server.URL, platform, sha256.Sum256([]byte("unit v1"))))
+	writeManifest(t, cfg.packagesDir, "v2", fmt.Sprintf(manifest, "v2", server.URL, platform, sha256.Sum256([]byte("unit v2"))))
+	writeManifest(t, cfg.packagesDir, "bad", fmt.Sprintf(manifest, "bad", server.URL, platform, sha256.Sum256([]byte("other"))))
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "v1")
+	assertFile(t, filepath.Join(root, "etc", "v1.service"))
+
+	// The cached v1 content no longer matches: it must be downloaded again.
+	server.handle("/tool.service", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "unit v2")
+	})
+	runGHPM(t, ghpm, cfg, "install", "v2")
+	data, err := os.ReadFile(filepath.Join(root, "etc", "v2.service"))
+	if err != nil {
+		t.Fatalf("read v2.service: %v", err)
+	}
+	if string(data) != "unit v2" {
+		t.Fatalf("unexpected content %q", data)
+	}
+
+	runGHPMExpectExit(t, ghpm, cfg, 5, "install", "bad")
+	assertMissing(t, filepath.Join(root, "etc", "bad.service"))
+}
+
+func TestInstallVerifiesGitHubDigest(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	server := newReleaseServer(t, "v1.0.0", map[string]string{"tool": "tool 1.0"})
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	writeManifest(t, cfg.packagesDir, "tool", githubManifest("tool", server, ""))
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "tool")
+	receipt, err := os.ReadFile(filepath.Join(cfg.stateDir, "receipts", "tool.json"))
+	if err != nil {
+		t.Fatalf("read receipt: %v", err)
+	}
+	if !bytes.Contains(receipt, []byte(fmt.Sprintf(`"digest": "sha256:%x"`, sha256.Sum256([]byte("tool 1.0"))))) {
+		t.Fatalf("receipt does not record the digest:\n%s", receipt)
+	}
+
+	// Corrupt the download cache: the asset must be fetched again.
+	cached, err := filepath.Glob(filepath.Join(cfg.cacheDir, "downloads", "*-tool"))
+	if err != nil || len(cached) != 1 {
+		t.Fatalf("find cached download: %v %v", cached, err)
+	}
+	if err := os.WriteFile(cached[0], []byte("corrupt"), 0o644); err != nil {
+		t.Fatalf("corrupt cache: %v", err)
+	}
+	runGHPM(t, ghpm, cfg, "install", "tool", "--force")
+	assertContent(t, filepath.Join(root, "bin", "tool"), "tool 1.0")
+
+	// Content that does not match upstream's digest is rejected.
+	server.handle("/acme/tool/releases/download/v1.0.0/tool", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "tampered")
+	})
+	if err := os.Remove(cached[0]); err != nil {
+		t.Fatalf("clear cache: %v", err)
+	}
+	runGHPMExpectExit(t, ghpm, cfg, 5, "install", "tool", "--force")
+}
+
+func TestInstallVerifiesSignatures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("integration tests require unix-like filesystem semantics")
+	}
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keyID := []byte("ghpmtest")
+	publicKey := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), pub...))
+	// sign returns a minisign signature: prehashed ("ED", minisign's default)
+	// or legacy ("Ed"), with a trusted comment. Legacy signatures without one
+	// are what signify produces.
+	sign := func(message, algorithm string, trusted bool) string {
+		signed := []byte(message)
+		if algorithm == "ED" {
+			sum := blake2b.Sum512(signed)
+			signed = sum[:]
+		}
+		sig := ed25519.Sign(priv, signed)
+		out := "untrusted comment: test\n" + base64.StdEncoding.EncodeToString(append(append([]byte(algorithm), keyID...), sig...)) + "\n"
+		if trusted {
+			comment := "timestamp:0"
+			global := ed25519.Sign(priv, append(append([]byte{}, sig...), comment...))
+			out += "trusted comment: " + comment + "\n" + base64.StdEncoding.EncodeToString(global) + "\n"
+		}
+		return out
+	}
+
+	server := newReleaseServer(t, "v1.0.0", map[string]string{
+		"tool":             "tool 1.0",
+		"tool.minisig":     sign("tool 1.0", "ED", true),
+		"tool.bad.minisig": sign("tool 0.9", "ED", true),
+	})
+	for path, content := range map[string]string{
+		"/tool.service":      "unit",
+		"/tool.service.sig":  sign("unit", "Ed", false),
+		"/tool.conf":         "conf",
+		"/tool.conf.minisig": sign("conf", "Ed", true),
+	} {
+		server.handle(path, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, content)
+		})
+	}
+
+	root := t.TempDir()
+	cfg := newTestLayout(t, root)
+	keysDir := filepath.Join(root, "keys")
+	if err := os.MkdirAll(keysDir, 0o755); err != nil {
+		t.Fatalf("create keys dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(keysDir, "acme.pub"), []byte("untrusted comment: minisign public key\n"+publicKey+"\n"), 0o644); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	// keysDir is resolved under --root.
+	if err := os.WriteFile(cfg.configPath, []byte("keysDir: /keys\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	writeManifest(t, cfg.packagesDir, "good", fmt.Sprintf(`name: good
+%[1]sinstall:
+  - type: asset
+    name: tool
+    target: bin/good
+    mode: "0755"
+    signature:
+      keys: [%[3]s]
+  - type: url
+    url: %[2]s/tool.service
+    target: etc/good.service
+    signature:
+      url: %[2]s/tool.service.sig
+      keyFiles: [acme.pub]
+  - type: url
+    url: %[2]s/tool.conf
+    target: etc/good.conf
+    signature:
+      keyFiles: [acme.pub]
+`, githubSource(server), server.URL, publicKey))
+	writeManifest(t, cfg.packagesDir, "bad", fmt.Sprintf(`name: bad
+%sinstall:
+  - type: asset
+    name: tool
+    target: bin/bad
+    mode: "0755"
+    signature:
+      name: "{asset}.bad.minisig"
+      keyFiles: [acme.pub]
+`, githubSource(server)))
+
+	ghpm := buildBinary(t)
+
+	runGHPM(t, ghpm, cfg, "install", "good")
+	assertExecutable(t, filepath.Join(root, "bin", "good"))
+	assertFile(t, filepath.Join(root, "etc", "good.service"))
+	assertFile(t, filepath.Join(root, "etc", "good.conf"))
+
+	runGHPMExpectExit(t, ghpm, cfg, 5, "install", "bad")
+	assertMissing(t, filepath.Join(root, "bin", "bad"))
+
+	// A local file has no default signature location.
+	writeManifest(t, cfg.packagesDir, "local", `name: local
+install:
+  - type: extract
+    from:
+      type: file
+      path: files/tool.tar.gz
+      signature:
+        keyFiles: [acme.pub]
+    targetDir: opt/local
+`)
+	output := runGHPMExpectExit(t, ghpm, cfg, 1, "install", "local")
+	if !strings.Contains(output, "extract.from.signature.url is required") {
+		t.Fatalf("unexpected error for a local file without signature.url:\n%s", output)
+	}
+}
+
+// testRelease is a release served by a releaseServer. assets maps asset names
+// to their content.
+type testRelease struct {
+	tag        string
+	prerelease bool
+	// latest marks the release upstream designates as latest; by default it
+	// is the first stable release.
+	latest    bool
+	published time.Time
+	assets    map[string]string
+}
+
+// releaseServer fakes a forge hosting acme/tool: the GitHub API under
+// /api/v3, Gitea's under /api/v1 and GitLab's under /api/v4, GitHub's web
+// pages (the latest release redirect and the Atom feed) and the release
+// downloads. Listings are paginated two entries per page and carry ETags.
+type releaseServer struct {
+	*httptest.Server
+	releases    []testRelease
+	digests     map[string]string
+	mu          sync.Mutex
+	routes      map[string]http.HandlerFunc
+	failures    map[string][]int
+	requests    map[string]int
+	notModified int
+	// authorizations holds the last Authorization header sent to each path.
+	authorizations map[string]string
+}
+
+func newReleaseServer(t *testing.T, tag string, assets map[string]string) *releaseServer {
+	t.Helper()
+	return newReleasesServer(t, testRelease{tag: tag, assets: assets})
+}
+
+// newReleasesServer serves releases, listed in the given order (newest first
+// on real forges). Tests override downloads and add files with handle.
+func newReleasesServer(t *testing.T, releases ...testRelease) *releaseServer {
+	t.Helper()
+	s := newUnstartedReleasesServer(t, releases)
+	s.Start()
+	return s
+}
+
+// newTLSReleasesServer is newReleasesServer over https, which ghpm requires
+// to send credentials. Clients must trust it, see trustServers.
+func newTLSReleasesServer(t *testing.T, releases ...testRelease) *releaseServer {
+	t.Helper()
+	s := newUnstartedReleasesServer(t, releases)
+	s.StartTLS()
+	return s
+}
+
+func newUnstartedReleasesServer(t *testing.T, releases []testRelease) *releaseServer {
+	s := &releaseServer{
+		releases:       releases,
+		digests:        map[string]string{},
+		routes:         map[string]http.HandlerFunc{},
+		failures:       map[string][]int{},
+		requests:       map[string]int{},
+		authorizations: map[string]string{},
+	}
+	for _, rel := range releases {
+		for name, content := range rel.assets {
+			s.digests[rel.tag+"/"+name] = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(content)))
+		}
+	}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// handle adds a route serving path.
+func (s *releaseServer) handle(path string, handler http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes[path] = handler
+}
+
+// fail makes the next requests to path fail with statuses, in order.
+func (s *releaseServer) fail(path string, statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = append(s.failures[path], statuses...)
+}
+
+// requestCount returns how many requests reached path, including failed and
+// not modified ones.
+func (s *releaseServer) requestCount(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+func (s *releaseServer) authorization(path string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.authorizations[path]
+}
+
+func (s *releaseServer) notModifiedCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notModified
+}
+
+func (s *releaseServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.URL.Path]++
+	s.authorizations[r.URL.Path] = r.Header.Get("Authorization")
+	route := s.routes[r.URL.Path]
+	var status int
+	if failures := s.failures[r.URL.Path]; len(failures) > 0 {
+		status, s.failures[r.URL.Path] = failures[0], failures[1:]
+	}
+	s.mu.Unlock()
+	switch {
+	case status != 0:
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, http.StatusText(status), status)
+		return
+	case route != nil:
+		route(w, r)
+		return
+	}
+
+	const (
+		github = "/api/v3/repos/acme/tool"
+		gitea  = "/api/v1/repos/acme/tool"
+		gitlab = "/api/v4/projects/acme/tool"
+		web    = "/acme/tool/releases"
+	)
+	path := r.URL.Path
+	switch {
+	case path == github+"/releases":
+		s.serveList(w, r, s.githubReleases(), "per_page=100")
+	case path == gitea+"/releases":
+		s.serveList(w, r, s.githubReleases(), "limit=50")
+	case path == gitlab+"/releases":
+		s.serveList(w, r, s.gitlabReleases(), "")
+	case path == github+"/releases/latest":
+		s.serveJSON(w, r, s.githubRelease(s.latest()))
+	case path == github+"/tags":
+		s.serveList(w, r, s.githubTags(), "per_page=100")
+	case strings.HasPrefix(path, github+"/commits/"):
+		rel, ok := s.release(strings.TrimPrefix(path, github+"/commits/"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		s.serveJSON(w, r, map[string]any{"commit": map[string]any{"committer": map[string]any{"date": rel.published}}})
+	case path == web+"/latest":
+		http.Redirect(w, r, web+"/tag/"+s.latest().tag, http.StatusFound)
+	case path == "/acme/tool/releases.atom":
+		s.serveFeed(w)
+	case strings.HasPrefix(path, web+"/download/"):
+		// Tags may contain slashes, asset names cannot.
+		rest := strings.TrimPrefix(path, web+"/download/")
+		slash := strings.LastIndex(rest, "/")
+		if slash < 0 {
+			http.NotFound(w, r)
+			return
+		}
+		tag, name := rest[:slash], rest[slash+1:]
+		content, ok := s.asset(tag, name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, content)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *releaseServer) release(tag string) (testRelease, bool) {
+	for _, rel := range s.releases {
+		if rel.tag == tag {
+			return rel, true
+		}
+	}
+	return testRelease{}, false
+}
+
+func (s *releaseServer) latest() testRelease {
+	for _, rel := range s.releases {
+		if rel.latest {
+			return rel
+		}
+	}
+	for _, rel := range s.releases {
+		if !rel.prerelease {
+			return rel
+		}
+	}
+	return testRelease{}
+}
+
+func (s *releaseServer) asset(tag, name string) (string, bool) {
+	rel, ok := s.release(tag)
+	if !ok {
+		return "", false
+	}
+	content, ok := rel.assets[name]
+	return content, ok
+}
+
+func (s *releaseServer) downloadURL(tag, name string) string {
+	return s.URL + "/acme/tool/releases/download/" + tag + "/" + name
+}
+
+func (s *releaseServer) githubRelease(rel testRelease) map[string]any {
+	var assets []map[string]any
+	for _, name := range sortedKeys(rel.assets) {
+		assets = append(assets, map[string]any{
+			"name":                 name,
+			"browser_download_url": s.downloadURL(rel.tag, name),
+			"url":                  s.URL + "/api/v3/repos/acme/tool/releases/assets/" + rel.tag + "/" + name,
+			"size":                 len(rel.assets[name]),
+			"digest":               s.digests[rel.tag+"/"+name],
+		})
+	}
+	return map[string]any{
+		"tag_name":     rel.tag,
+		"prerelease":   rel.prerelease,
+		"published_at": rel.published,
+		"assets":       assets,
+	}
+}
+
+func (s *releaseServer) githubReleases() []any {
+	var list []any
+	for _, rel := range s.releases {
+		list = append(list, s.githubRelease(rel))
+	}
+	return list
+}
+
+func (s *releaseServer) gitlabReleases() []any {
+	var list []any
+	for _, rel := range s.releases {
+		var links []map[string]any
+		for _, name := range sortedKeys(rel.assets) {
+			links = append(links, map[string]any{"name": name, "url": s.downloadURL(rel.tag, name)})
+		}
+		list = append(list, map[string]any{
+			"tag_name":         rel.tag,
+			"released_at":      rel.published,
+			"upcoming_release": rel.prerelease,
+			"assets":           map[string]any{"links": links},
+		})
+	}
+	return list
+}
+
+func (s *releaseServer) githubTags() []any {
+	var list []any
+	for _, rel := range s.releases {
+		list = append(list, map[string]any{
+			"name":        rel.tag,
+			"tarball_url": s.downloadURL(rel.tag, "source.tar.gz"),
+			"zipball_url": s.downloadURL(rel.tag, "source.zip"),
+			"commit":      map[string]any{"url": s.URL + "/api/v3/repos/acme/tool/commits/" + rel.tag},
+		})
+	}
+	return list
+}
+
+// serveList serves one page of items, linking the next one with a Link
+// header when query is set (GitHub, Gitea) and X-Next-Page otherwise
+// (GitLab).
+func (s *releaseServer) serveList(w http.ResponseWriter, r *http.Request, items []any, query string) {
+	const perPage = 2
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	start := min((page-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	if end < len(items) {
+		next := strconv.Itoa(page + 1)
+		if query != "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?%s&page=%s>; rel="next"`, s.URL, r.URL.Path, query, next))
+		} else {
+			w.Header().Set("X-Next-Page", next)
+		}
+	}
+	s.serveJSON(w, r, append([]any{}, items[start:end]...))
+}
+
+func (s *releaseServer) serveJSON(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(body))
+	if r.Header.Get("If-None-Match") == etag {
+		s.mu.Lock()
+		s.notModified++
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag)
+	w.Write(body)
+}
+
+func (s *releaseServer) serveFeed(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/atom+xml")
+	fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><feed xmlns="http://www.w3.org/2005/Atom">`)
+	for _, rel := range s.releases {
+		fmt.Fprintf(w, `<entry><updated>%s</updated><link rel="alternate" type="text/html" href="%s/acme/tool/releases/tag/%s"/></entry>`,
+			rel.published.Format(time.RFC3339), s.URL, rel.tag)
+	}
+	fmt.Fprint(w, `</feed>`)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// githubSource returns the source block of a manifest for acme/tool on
+// server.
+func githubSource(server *releaseServer) string {
+	return fmt.Sprintf("source:\n  kind: github\n  repo: acme/tool\n  host: %s\n", server.URL)
+}
+
+// githubManifest returns a manifest installing the asset tool of acme/tool
+// to /bin/<name>. extra follows the source fields: indented lines extend the
+// source, unindented ones add top-level fields.
+func githubManifest(name string, server *releaseServer, extra string) string {
+	return fmt.Sprintf(`name: %s
+%s%sinstall:
+  - type: asset
+    name: tool
+    target: "/bin/%[1]s"
+    mode: "0755"
+`, name, githubSource(server), extra)
 }
 
 type testLayout struct {
@@ -70,6 +1640,8 @@ type testLayout struct {
 	stateDir    string
 	cacheDir    string
 	configPath  string
+	// env is added to the environment of ghpm.
+	env []string
 }
 
 func newTestLayout(t *testing.T, root string) testLayout {
@@ -92,6 +1664,20 @@ func newTestLayout(t *testing.T, root string) testLayout {
 	return layout
 }
 
+// trustServers makes ghpm trust the certificates of TLS test servers.
+func trustServers(t *testing.T, cfg *testLayout, servers ...*httptest.Server) {
+	t.Helper()
+	var certs bytes.Buffer
+	for _, server := range servers {
+		pem.Encode(&certs, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, certs.Bytes(), 0o644); err != nil {
+		t.Fatalf("write certificates: %v", err)
+	}
+	cfg.env = append(cfg.env, "SSL_CERT_FILE="+path)
+}
+
 func writeManifest(t *testing.T, packagesDir, name, content string) {
 	t.Helper()
 	pkgDir := filepath.Join(packagesDir, name)
@@ -127,8 +1713,30 @@ func repoRoot(t *testing.T) string {
 	return filepath.Dir(filepath.Dir(file))
 }
 
-func runGHPM(t *testing.T, ghpm string, cfg testLayout, args ...string) {
+// runGHPM runs ghpm and returns its combined output.
+func runGHPM(t *testing.T, ghpm string, cfg testLayout, args ...string) string {
+	t.Helper()
+	cmd, buf := ghpmCommand(ghpm, cfg, args...)
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("ghpm %v failed: %v\n%s", args, err, buf.String())
+	}
+	return buf.String()
+}
+
+// runGHPMExpectExit runs ghpm, fails unless it exits with code and returns
+// its combined output.
+func runGHPMExpectExit(t *testing.T, ghpm string, cfg testLayout, code int, args ...string) string {
 	t.Helper()
+	cmd, buf := ghpmCommand(ghpm, cfg, args...)
+	err := cmd.Run()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != code {
+		t.Fatalf("ghpm %v: got %v, want exit status %d\n%s", args, err, code, buf.String())
+	}
+	return buf.String()
+}
+
+func ghpmCommand(ghpm string, cfg testLayout, args ...string) (*exec.Cmd, *bytes.Buffer) {
 	baseArgs := []string{
 		"--root", cfg.root,
 		"--packages-dir", "var/lib/ghpm/packages",
@@ -137,12 +1745,11 @@ func runGHPM(t *testing.T, ghpm string, cfg testLayout, args ...string) {
 		"--config", cfg.configPath,
 	}
 	cmd := exec.Command(ghpm, append(baseArgs, args...)...)
+	cmd.Env = append(os.Environ(), cfg.env...)
 	var buf bytes.Buffer
 	cmd.Stdout = &buf
 	cmd.Stderr = &buf
-	if err := cmd.Run(); err != nil {
-		t.Fatalf("ghpm %v failed: %v\n%s", args, err, buf.String())
-	}
+	return cmd, &buf
 }
 
 func assertExecutable(t *testing.T, path string) {
@@ -167,6 +1774,17 @@ func assertFile(t *testing.T, path string) {
 	}
 }
 
+func assertContent(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	if string(data) != want {
+		t.Fatalf("unexpected content %q in %s, want %q", data, path, want)
+	}
+}
+
 func assertMissing(t *testing.T, path string) {
 	t.Helper()
 	if _, err := os.Stat(path); err == nil {